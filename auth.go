@@ -0,0 +1,166 @@
+package appstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrUnauthorized matches every AuthenticationError with errors.Is.
+var ErrUnauthorized = errors.New("appstore api: unauthorized")
+
+// TokenClaims summarizes the bearer token a rejected request carried. Identifiers are redacted
+// to their first characters so the summary can be logged, and the signature is never kept.
+type TokenClaims struct {
+	KeyID      string    // kid header, redacted
+	KeyType    KeyType   // Key type of the client, or inferred from sub when unknown
+	Issuer     string    // iss claim, redacted
+	Subject    string    // sub claim, "user" for individual keys
+	Audience   string    // aud claim
+	BundleID   string    // bid claim
+	IssuedAt   time.Time // iat claim
+	ExpiresAt  time.Time // exp claim
+	ServerDate time.Time // Date header of the 401 response, zero when missing
+}
+
+// Skew is how far the token's iat is ahead of the server clock, 0 when the server date is unknown.
+func (c TokenClaims) Skew() time.Duration {
+	if c.ServerDate.IsZero() || c.IssuedAt.IsZero() {
+		return 0
+	}
+	return c.IssuedAt.Sub(c.ServerDate)
+}
+
+// Hints lists the likely reasons Apple rejected the token.
+func (c TokenClaims) Hints() []string {
+	var hints []string
+	if c.KeyID == "" {
+		hints = append(hints, "kid is missing")
+	}
+	if c.KeyType == IndividualKey {
+		if c.Subject != "user" {
+			hints = append(hints, fmt.Sprintf("sub is %q instead of \"user\" for an individual key", c.Subject))
+		}
+		if c.Issuer != "" {
+			hints = append(hints, "iss is set for an individual key")
+		}
+	} else if c.Issuer == "" {
+		hints = append(hints, "iss is missing")
+	}
+	if c.BundleID == "" {
+		hints = append(hints, "bid is missing")
+	}
+	if c.Audience != DefaultAudience {
+		hints = append(hints, fmt.Sprintf("aud is %q instead of %q", c.Audience, DefaultAudience))
+	}
+	if lifetime := c.ExpiresAt.Sub(c.IssuedAt); lifetime > time.Hour {
+		hints = append(hints, fmt.Sprintf("token lifetime %s exceeds 60 minutes", lifetime))
+	}
+	if skew := c.Skew(); skew > time.Minute {
+		hints = append(hints, fmt.Sprintf("iat is %s ahead of the server clock", skew))
+	}
+	if !c.ServerDate.IsZero() && !c.ExpiresAt.After(c.ServerDate) {
+		hints = append(hints, "token expired by the server clock")
+	}
+	return hints
+}
+
+func (c TokenClaims) String() string {
+	return fmt.Sprintf("kid=%s iss=%s sub=%s aud=%s bid=%s iat=%s exp=%s skew=%s",
+		c.KeyID, c.Issuer, c.Subject, c.Audience, c.BundleID,
+		c.IssuedAt.UTC().Format(time.RFC3339), c.ExpiresAt.UTC().Format(time.RFC3339), c.Skew())
+}
+
+// AuthenticationError is returned when the App Store Server API answers 401 Unauthorized,
+// which means Apple rejected the bearer token rather than the request itself.
+type AuthenticationError struct {
+	Claims    TokenClaims // Summary of the rejected token.
+	Refreshed bool        // Whether a freshly generated token was rejected as well.
+	Err       *Error      // The response error, with status, headers and body.
+}
+
+// newAuthenticationError summarizes the rejected bearer. keyType is the client's key type, empty when unknown.
+func newAuthenticationError(respErr *Error, bearer string, keyType KeyType, refreshed bool) *AuthenticationError {
+	claims := decodeTokenClaims(bearer)
+	if keyType != "" {
+		claims.KeyType = keyType
+	}
+	if date, err := http.ParseTime(respErr.header.Get("Date")); err == nil {
+		claims.ServerDate = date
+	}
+	return &AuthenticationError{Claims: claims, Refreshed: refreshed, Err: respErr}
+}
+
+func (e *AuthenticationError) Error() string {
+	msg := fmt.Sprintf("appstore api: %s unauthorized, token %s", e.Err.endpoint, e.Claims)
+	if hints := e.Claims.Hints(); len(hints) > 0 {
+		msg += ": " + strings.Join(hints, ", ")
+	}
+	return msg
+}
+
+func (e *AuthenticationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *AuthenticationError) Is(target error) bool {
+	return target == ErrUnauthorized
+}
+
+// decodeTokenClaims reads the header and claims of a bearer token without verifying it.
+func decodeTokenClaims(bearer string) TokenClaims {
+	var claims TokenClaims
+	parts := strings.Split(bearer, ".")
+	if len(parts) != 3 {
+		return claims
+	}
+
+	var header struct {
+		Kid string `json:"kid"`
+	}
+	if b, err := base64.RawURLEncoding.DecodeString(parts[0]); err == nil && json.Unmarshal(b, &header) == nil {
+		claims.KeyID = redactID(header.Kid)
+	}
+
+	var payload struct {
+		Iss string `json:"iss"`
+		Sub string `json:"sub"`
+		Aud string `json:"aud"`
+		Bid string `json:"bid"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}
+	if b, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil && json.Unmarshal(b, &payload) == nil {
+		claims.Issuer = redactID(payload.Iss)
+		claims.Subject = payload.Sub
+		claims.KeyType = TeamKey
+		if payload.Sub == "user" {
+			claims.KeyType = IndividualKey
+		}
+		claims.Audience = payload.Aud
+		claims.BundleID = payload.Bid
+		if payload.Iat != 0 {
+			claims.IssuedAt = time.Unix(payload.Iat, 0)
+		}
+		if payload.Exp != 0 {
+			claims.ExpiresAt = time.Unix(payload.Exp, 0)
+		}
+	}
+	return claims
+}
+
+// redactID keeps the first four characters of an identifier, enough to tell keys apart in logs.
+func redactID(id string) string {
+	if len(id) <= 4 {
+		return id
+	}
+	return id[:4] + strings.Repeat("*", len(id)-4)
+}
+
+func bearerFromRequest(req *http.Request) string {
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+}
@@ -0,0 +1,90 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreClient_Unauthorized(t *testing.T) {
+	var bearers []string
+	rejectAll := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bearers = append(bearers, r.Header.Get("Authorization"))
+		if rejectAll || len(bearers) == 1 {
+			w.Header().Set("Date", time.Now().Add(-3*time.Minute).UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Unauthenticated\n"))
+			return
+		}
+		w.Write([]byte(`{"signedTransactionInfo":"jws"}`))
+	}))
+	defer srv.Close()
+
+	a := NewStoreClient(newTestStoreConfig(t, srv.URL))
+	if _, err := a.GetTransactionInfo(context.TODO(), "1000"); err != nil {
+		t.Fatalf("GetTransactionInfo() error = %v, want success with a refreshed token", err)
+	}
+	if len(bearers) != 2 || bearers[0] == bearers[1] {
+		t.Fatalf("sent %d requests, want a second one with a new token", len(bearers))
+	}
+
+	rejectAll, bearers = true, nil
+	_, err := a.GetTransactionInfo(context.TODO(), "1000")
+	var authErr *AuthenticationError
+	if !errors.As(err, &authErr) || !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("GetTransactionInfo() error = %v, want an AuthenticationError", err)
+	}
+	if len(bearers) != 2 || !authErr.Refreshed || authErr.Err.HTTPStatus() != http.StatusUnauthorized {
+		t.Errorf("sent %d requests, refreshed = %v, status = %d", len(bearers), authErr.Refreshed, authErr.Err.HTTPStatus())
+	}
+	claims := authErr.Claims
+	if claims.KeyID != "SKEY**" || claims.Issuer != "5724"+strings.Repeat("*", 32) || claims.BundleID != "fake.bundle.id" || claims.Audience != DefaultAudience {
+		t.Errorf("Claims = %s", claims)
+	}
+	if skew := claims.Skew(); skew < time.Minute || skew > 3*time.Minute {
+		t.Errorf("Claims.Skew() = %s, want about two minutes, iat backdated by one and the server three behind", skew)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "ahead of the server clock") || strings.Contains(msg, "57246542") {
+		t.Errorf("Error() = %q, want a skew hint and a redacted issuer", msg)
+	}
+}
+
+func TestTokenClaims_Hints(t *testing.T) {
+	config := newTestStoreConfig(t, "")
+	config.KeyType, config.Issuer = IndividualKey, ""
+	token := &Token{}
+	token.WithConfig(config)
+	bearer, err := token.GenerateIfExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := decodeTokenClaims(bearer)
+	if claims.KeyType != IndividualKey || claims.Subject != "user" {
+		t.Fatalf("decodeTokenClaims() = %s, want an individual key with sub user", claims)
+	}
+	if hints := claims.Hints(); len(hints) != 0 {
+		t.Errorf("Hints() = %q, want none for a well-formed individual key token", hints)
+	}
+
+	for name, tt := range map[string]struct {
+		claims TokenClaims
+		want   string
+	}{
+		"team without iss":       {claims: TokenClaims{KeyType: TeamKey}, want: "iss is missing"},
+		"individual without sub": {claims: TokenClaims{KeyType: IndividualKey}, want: `sub is "" instead of "user" for an individual key`},
+		"individual with iss":    {claims: TokenClaims{KeyType: IndividualKey, Subject: "user", Issuer: "5724****"}, want: "iss is set for an individual key"},
+		"individual":             {claims: TokenClaims{KeyType: IndividualKey, Subject: "user"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tt.claims.KeyID, tt.claims.BundleID, tt.claims.Audience = "SKEY**", "fake.bundle.id", DefaultAudience
+			if hints := strings.Join(tt.claims.Hints(), ", "); hints != tt.want {
+				t.Errorf("Hints() = %q, want %q", hints, tt.want)
+			}
+		})
+	}
+}
@@ -2,28 +2,83 @@ package appstore
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
+// Backoff returns the pause before the next attempt of a single request, or a negative duration to stop retrying.
 type Backoff interface {
 	Pause() time.Duration
 }
 
+// BackoffFactory creates a fresh Backoff for every request, so concurrent requests never share retry state.
+// SetRetry and RetryPolicy call NewBackoff once per request when the configured Backoff implements it.
+type BackoffFactory interface {
+	NewBackoff() Backoff
+}
+
+// BackoffFactoryFunc adapts a function to BackoffFactory.
+type BackoffFactoryFunc func() Backoff
+
+func (f BackoffFactoryFunc) NewBackoff() Backoff {
+	return f()
+}
+
+// Rand is the random source of the jittered backoffs. *rand.Rand satisfies it, but is not safe for concurrent use.
+type Rand interface {
+	Int63n(n int64) int64
+}
+
+type globalRand struct{}
+
+func (globalRand) Int63n(n int64) int64 {
+	return rand.Int63n(n)
+}
+
+func randOrGlobal(r Rand) Rand {
+	if r == nil {
+		return globalRand{}
+	}
+	return r
+}
+
+// randBetween returns a random duration in [lo, hi].
+func randBetween(r Rand, lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(r.Int63n(int64(hi-lo)+1))
+}
+
 type OneSecondBackoff struct{}
 
 func (bo *OneSecondBackoff) Pause() time.Duration {
 	return time.Duration(1) * time.Second
 }
 
+func (bo *OneSecondBackoff) NewBackoff() Backoff {
+	return bo
+}
+
+// JitterBackoff draws every pause between zero and a ceiling that grows by Multiplier from Initial,
+// and gives up once the ceiling passes Max.
 type JitterBackoff struct {
 	Initial    time.Duration
 	Max        time.Duration
 	Multiplier float64
+	MaxElapsed time.Duration // Stop once the next pause would end later than this after the first pause. Default is no limit.
+	Clock      Clock         // Measures MaxElapsed, see RetryPolicy. Default is the system clock.
+	Rand       Rand          // Default is the math/rand global source.
 
-	cur time.Duration
+	mu      sync.Mutex
+	cur     time.Duration
+	elapsed *elapsedLimit
 }
 
 func (bo *JitterBackoff) Pause() time.Duration {
+	bo.mu.Lock()
+	defer bo.mu.Unlock()
+
 	if bo.Initial == 0 {
 		bo.Initial = time.Second
 	}
@@ -37,13 +92,170 @@ func (bo *JitterBackoff) Pause() time.Duration {
 		bo.Multiplier = 2
 	}
 
+	if bo.elapsed == nil {
+		l := newElapsedLimit(clockOrSystem(bo.Clock), bo.MaxElapsed)
+		bo.elapsed = &l
+	}
+
 	// https://www.awsarchitectureblog.com/2015/03/backoff.html
-	d := time.Duration(1 + rand.Int63n(int64(bo.cur)))
+	d := time.Duration(1 + randOrGlobal(bo.Rand).Int63n(int64(bo.cur)))
 	bo.cur = time.Duration(float64(bo.cur) * bo.Multiplier)
 
 	// stop the backoff
 	if bo.cur > bo.Max {
-		d = -1
+		return -1
+	}
+	return bo.elapsed.check(d)
+}
+
+// Reset starts the backoff over from Initial.
+func (bo *JitterBackoff) Reset() {
+	bo.mu.Lock()
+	bo.cur = 0
+	bo.elapsed = nil
+	bo.mu.Unlock()
+}
+
+// NewBackoff returns a JitterBackoff with the same settings and fresh state.
+func (bo *JitterBackoff) NewBackoff() Backoff {
+	bo.mu.Lock()
+	defer bo.mu.Unlock()
+	return &JitterBackoff{Initial: bo.Initial, Max: bo.Max, Multiplier: bo.Multiplier, MaxElapsed: bo.MaxElapsed, Clock: bo.Clock, Rand: bo.Rand}
+}
+
+// DecorrelatedJitterBackoff draws every pause between Base and three times the previous pause, capped at Cap.
+// https://www.awsarchitectureblog.com/2015/03/backoff.html
+type DecorrelatedJitterBackoff struct {
+	Base       time.Duration // Default is one second.
+	Cap        time.Duration // Default is 30 seconds.
+	MaxElapsed time.Duration // Stop once the next pause would end later than this after the request started. Default is no limit.
+	Clock      Clock         // Measures MaxElapsed, see RetryPolicy. Default is the system clock.
+	Rand       Rand          // Default is the math/rand global source.
+}
+
+func (s *DecorrelatedJitterBackoff) NewBackoff() Backoff {
+	bo := &decorrelatedJitterBackoff{
+		base:  s.Base,
+		cap:   s.Cap,
+		clock: clockOrSystem(s.Clock),
+		rand:  randOrGlobal(s.Rand),
+	}
+	if bo.base <= 0 {
+		bo.base = time.Second
+	}
+	if bo.cap <= 0 {
+		bo.cap = 30 * time.Second
+	}
+	bo.elapsed = newElapsedLimit(bo.clock, s.MaxElapsed)
+	bo.sleep = bo.base
+	return bo
+}
+
+type decorrelatedJitterBackoff struct {
+	base, cap time.Duration
+	sleep     time.Duration
+	clock     Clock
+	rand      Rand
+	elapsed   elapsedLimit
+}
+
+func (bo *decorrelatedJitterBackoff) Pause() time.Duration {
+	bo.sleep = randBetween(bo.rand, bo.base, bo.sleep*3)
+	if bo.sleep > bo.cap {
+		bo.sleep = bo.cap
+	}
+	return bo.elapsed.check(bo.sleep)
+}
+
+// ExponentialBackoff multiplies the pause by Multiplier after every attempt up to Max,
+// and keeps retrying at Max instead of giving up like JitterBackoff does.
+type ExponentialBackoff struct {
+	Initial    time.Duration // Default is one second.
+	Max        time.Duration // Default is 30 seconds.
+	Multiplier float64       // Default is 2.
+	Jitter     float64       // Fraction of each pause that is randomized, between 0 and 1. Default is none.
+	MaxElapsed time.Duration // Stop once the next pause would end later than this after the request started. Default is no limit.
+	Clock      Clock         // Measures MaxElapsed, see RetryPolicy. Default is the system clock.
+	Rand       Rand          // Default is the math/rand global source.
+}
+
+func (s *ExponentialBackoff) NewBackoff() Backoff {
+	bo := &exponentialBackoff{
+		cur:        s.Initial,
+		max:        s.Max,
+		multiplier: s.Multiplier,
+		jitter:     s.Jitter,
+		clock:      clockOrSystem(s.Clock),
+		rand:       randOrGlobal(s.Rand),
+	}
+	if bo.cur <= 0 {
+		bo.cur = time.Second
+	}
+	if bo.max <= 0 {
+		bo.max = 30 * time.Second
+	}
+	if bo.cur > bo.max {
+		bo.cur = bo.max
+	}
+	if bo.multiplier < 1 {
+		bo.multiplier = 2
+	}
+	if bo.jitter < 0 || bo.jitter > 1 {
+		bo.jitter = 0
+	}
+	bo.elapsed = newElapsedLimit(bo.clock, s.MaxElapsed)
+	return bo
+}
+
+type exponentialBackoff struct {
+	cur, max   time.Duration
+	multiplier float64
+	jitter     float64
+	clock      Clock
+	rand       Rand
+	elapsed    elapsedLimit
+}
+
+func (bo *exponentialBackoff) Pause() time.Duration {
+	d := bo.cur
+	if bo.jitter > 0 {
+		spread := time.Duration(float64(d) * bo.jitter)
+		d = randBetween(bo.rand, d-spread, d)
+	}
+
+	next := time.Duration(float64(bo.cur) * bo.multiplier)
+	if next > bo.max || next < bo.cur {
+		next = bo.max
+	}
+	bo.cur = next
+	return bo.elapsed.check(d)
+}
+
+// elapsedLimit stops a backoff once the total time since it was created would exceed max.
+type elapsedLimit struct {
+	clock Clock
+	max   time.Duration
+	start time.Time
+}
+
+func newElapsedLimit(clock Clock, max time.Duration) elapsedLimit {
+	return elapsedLimit{clock: clock, max: max, start: clock.Now()}
+}
+
+func (l *elapsedLimit) check(pause time.Duration) time.Duration {
+	if l.max <= 0 {
+		return pause
+	}
+	if l.clock.Now().Add(pause).Sub(l.start) > l.max {
+		return -1
+	}
+	return pause
+}
+
+// newRequestBackoff returns the backoff for one request: a fresh one when bo is a factory, bo itself otherwise.
+func newRequestBackoff(bo Backoff) Backoff {
+	if f, ok := bo.(BackoffFactory); ok {
+		return f.NewBackoff()
 	}
-	return d
+	return bo
 }
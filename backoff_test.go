@@ -0,0 +1,105 @@
+package appstore
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	s := &ExponentialBackoff{Initial: time.Second, Max: 4 * time.Second, MaxElapsed: 10 * time.Second, Clock: clock}
+
+	bo := s.NewBackoff()
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, -1}
+	for i, w := range want {
+		got := bo.Pause()
+		if got != w {
+			t.Fatalf("Pause() #%d = %v, want %v", i, got, w)
+		}
+		clock.Advance(got)
+	}
+
+	if got := s.NewBackoff().Pause(); got != time.Second {
+		t.Errorf("Pause() of a new backoff = %v, want it to start over", got)
+	}
+}
+
+func TestDecorrelatedJitterBackoff(t *testing.T) {
+	s := &DecorrelatedJitterBackoff{Base: 100 * time.Millisecond, Cap: time.Second, Rand: rand.New(rand.NewSource(1))}
+	bo := s.NewBackoff()
+	prev := s.Base
+	for i := 0; i < 20; i++ {
+		got := bo.Pause()
+		if got < s.Base || got > s.Cap || got > 3*prev {
+			t.Fatalf("Pause() #%d = %v, want between %v and min(%v, %v)", i, got, s.Base, s.Cap, 3*prev)
+		}
+		prev = got
+	}
+
+	newSeeded := func() Backoff {
+		return (&DecorrelatedJitterBackoff{Base: 100 * time.Millisecond, Cap: time.Second, Rand: rand.New(rand.NewSource(1))}).NewBackoff()
+	}
+	if newSeeded().Pause() != newSeeded().Pause() {
+		t.Errorf("Pause() differs for the same seed")
+	}
+}
+
+func TestJitterBackoff_PerRequest(t *testing.T) {
+	shared := &JitterBackoff{Initial: time.Millisecond, Max: 4 * time.Millisecond}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bo := newRequestBackoff(shared)
+			if bo == Backoff(shared) {
+				t.Errorf("newRequestBackoff() returned the shared backoff")
+			}
+			for j := 0; j < 2; j++ {
+				if d := bo.Pause(); d < 0 {
+					t.Errorf("Pause() #%d = %v, want a fresh backoff per request", j, d)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	shared.Pause()
+	shared.Pause()
+	shared.Reset()
+	if d := shared.Pause(); d < 0 {
+		t.Errorf("Pause() after Reset() = %v", d)
+	}
+}
+
+func TestJitterBackoff(t *testing.T) {
+	newSeeded := func(clock Clock) Backoff {
+		s := &JitterBackoff{Initial: time.Second, Max: time.Minute, MaxElapsed: 10 * time.Second, Clock: clock, Rand: rand.New(rand.NewSource(1))}
+		return s.NewBackoff()
+	}
+	a, b := newSeeded(&fakeClock{now: time.Unix(1700000000, 0)}), newSeeded(&fakeClock{now: time.Unix(1700000000, 0)})
+	ceiling := time.Second
+	for i := 0; i < 3; i++ {
+		got := a.Pause()
+		if got != b.Pause() {
+			t.Fatalf("Pause() #%d differs for the same seed", i)
+		}
+		if got <= 0 || got > ceiling {
+			t.Fatalf("Pause() #%d = %v, want within (0, %v]", i, got, ceiling)
+		}
+		ceiling *= 2
+	}
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	bo := newSeeded(clock)
+	if d := bo.Pause(); d < 0 {
+		t.Fatalf("Pause() = %v, want a pause", d)
+	}
+	clock.Advance(10 * time.Second)
+	if d := bo.Pause(); d != -1 {
+		t.Errorf("Pause() after MaxElapsed = %v, want -1", d)
+	}
+}
@@ -0,0 +1,252 @@
+package appstore
+
+import (
+	"container/list"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ResponseCache stores raw response bodies of read endpoints. Implementations must be safe for concurrent use.
+type ResponseCache interface {
+	// Get returns the value stored under key, unless it expired.
+	Get(key string) ([]byte, bool)
+	// Set stores value under key for ttl and indexes it under every tag.
+	Set(key string, value []byte, ttl time.Duration, tags []string)
+	// Invalidate drops every value indexed under tag.
+	Invalidate(tag string)
+}
+
+// CacheGeneration is implemented by a ResponseCache that counts its invalidations. A client stores a
+// response only if the count did not move while fetching it, so an invalidation through any client
+// sharing the cache keeps a stale response out. Without it, only the client's own invalidations count.
+type CacheGeneration interface {
+	Generation() uint64
+}
+
+// DefaultCacheTTLs are the endpoints StoreClient caches when StoreConfig.Cache is set and CacheTTLs is not.
+var DefaultCacheTTLs = map[Endpoint]time.Duration{
+	EndpointGetTransactionInfo:         5 * time.Minute,
+	EndpointGetAllSubscriptionStatuses: 30 * time.Second,
+	EndpointLookUpOrderID:              5 * time.Minute,
+}
+
+type LRUCacheConfig struct {
+	Capacity int   // Maximum number of entries. Default is 1024.
+	Clock    Clock // Default is the system clock.
+}
+
+// LRUCache is an in-memory ResponseCache that evicts the least recently used entry once it is full.
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	clock    Clock
+	order    *list.List // front is the most recently used
+	entries  map[string]*list.Element
+	tags     map[string]map[string]struct{} // tag to keys
+	gen      uint64                         // incremented by Invalidate
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	tags      []string
+}
+
+func NewLRUCache(config LRUCacheConfig) *LRUCache {
+	capacity := config.Capacity
+	if capacity <= 0 {
+		capacity = 1024
+	}
+	return &LRUCache{
+		capacity: capacity,
+		clock:    clockOrSystem(config.Clock),
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		tags:     make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if !c.clock.Now().Before(e.expiresAt) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration, tags []string) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	e := &lruEntry{key: key, value: value, expiresAt: c.clock.Now().Add(ttl), tags: tags}
+	c.entries[key] = c.order.PushFront(e)
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRUCache) Invalidate(tag string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for key := range c.tags[tag] {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+		}
+	}
+	delete(c.tags, tag)
+}
+
+// Generation returns the number of Invalidate calls so far.
+func (c *LRUCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// Len returns the number of entries, expired ones included until they are looked up or evicted.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRUCache) remove(el *list.Element) {
+	e := c.order.Remove(el).(*lruEntry)
+	delete(c.entries, e.key)
+	for _, tag := range e.tags {
+		if keys, ok := c.tags[tag]; ok {
+			delete(keys, e.key)
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
+		}
+	}
+}
+
+// cacheTTL returns how long responses of endpoint are cached, 0 when they are not.
+func (c *StoreClient) cacheTTL(endpoint Endpoint) time.Duration {
+	if c.cache == nil {
+		return 0
+	}
+	return c.cacheTTLs[endpoint]
+}
+
+// cacheGeneration returns the invalidation count of the cache, or of the client when the cache doesn't count them.
+func (c *StoreClient) cacheGeneration() uint64 {
+	if g, ok := c.cache.(CacheGeneration); ok {
+		return g.Generation()
+	}
+	return uint64(atomic.LoadUint32(&c.cacheGen))
+}
+
+func (c *StoreClient) cacheGet(ttl time.Duration, key string) ([]byte, bool) {
+	if ttl <= 0 {
+		return nil, false
+	}
+	return c.cache.Get(key)
+}
+
+// cacheTag scopes an originalTransactionId to the client's app, so clients of several apps can share one cache.
+func (c *StoreClient) cacheTag(originalTransactionId string) string {
+	return c.Token.BundleID + "|" + originalTransactionId
+}
+
+// InvalidateCache drops every cached response that involves originalTransactionId.
+// Call it when an App Store Server Notification reports a change to the transaction.
+func (c *StoreClient) InvalidateCache(originalTransactionId string) {
+	if c.cache == nil {
+		return
+	}
+	atomic.AddUint32(&c.cacheGen, 1)
+	c.cache.Invalidate(c.cacheTag(originalTransactionId))
+}
+
+// cacheTags indexes a response under the transaction identifiers of the request and of the
+// transactions it returns. The signed transactions are only decoded, their signature is not verified.
+func (c *StoreClient) cacheTags(r *apiRequest, body []byte) []string {
+	var ids []string
+	for _, name := range []string{"transactionId", "originalTransactionId"} {
+		if id := r.pathParams[name]; id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	switch r.endpoint {
+	case EndpointGetTransactionInfo:
+		var rsp TransactionInfoResponse
+		if json.Unmarshal(body, &rsp) == nil {
+			ids = append(ids, unverifiedOriginalTransactionID(rsp.SignedTransactionInfo))
+		}
+	case EndpointLookUpOrderID:
+		var rsp OrderLookupResponse
+		if json.Unmarshal(body, &rsp) == nil {
+			for _, signed := range rsp.SignedTransactions {
+				ids = append(ids, unverifiedOriginalTransactionID(signed))
+			}
+		}
+	case EndpointGetAllSubscriptionStatuses:
+		var rsp StatusResponse
+		if json.Unmarshal(body, &rsp) == nil {
+			for _, group := range rsp.Data {
+				for _, item := range group.LastTransactions {
+					ids = append(ids, item.OriginalTransactionId)
+				}
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			tags = append(tags, c.cacheTag(id))
+		}
+	}
+	return tags
+}
+
+func unverifiedOriginalTransactionID(jws string) string {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		OriginalTransactionId string `json:"originalTransactionId"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	return claims.OriginalTransactionId
+}
@@ -0,0 +1,110 @@
+package appstore
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	c := NewLRUCache(LRUCacheConfig{Capacity: 2, Clock: clock})
+
+	c.Set("a", []byte("1"), time.Minute, []string{"t1"})
+	c.Set("b", []byte("2"), time.Minute, []string{"t1", "t2"})
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("Get(a) missed")
+	}
+	c.Set("c", []byte("3"), time.Minute, nil)
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Get(b) hit, want the least recently used entry evicted")
+	}
+
+	c.Invalidate("t1")
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Get(a) hit after Invalidate(t1)")
+	}
+	if v, ok := c.Get("c"); !ok || string(v) != "3" {
+		t.Errorf("Get(c) = %q, %v, want 3, true", v, ok)
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("c"); ok || c.Len() != 0 {
+		t.Errorf("Get(c) hit after its TTL, Len() = %d", c.Len())
+	}
+}
+
+func TestStoreClient_Cache(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"transactionId":"2000","originalTransactionId":"1000"}`))
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(`{"signedTransactionInfo":"header.` + payload + `.signature"}`))
+	}))
+	defer srv.Close()
+
+	c := newTestStoreConfig(t, srv.URL)
+	c.Cache = NewLRUCache(LRUCacheConfig{})
+	a := NewStoreClient(c)
+
+	for i := 0; i < 2; i++ {
+		if _, err := a.GetTransactionInfo(context.TODO(), "2000"); err != nil {
+			t.Fatalf("GetTransactionInfo() error = %v", err)
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("server hits = %d, want the second call served from cache", hits)
+	}
+
+	a.InvalidateCache("1000")
+	if _, err := a.GetTransactionInfo(context.TODO(), "2000"); err != nil || hits != 2 {
+		t.Errorf("GetTransactionInfo() error = %v, hits = %d, want a fetch after InvalidateCache", err, hits)
+	}
+
+	if _, err := a.GetRefundHistory(context.TODO(), "1000"); err != nil {
+		t.Fatalf("GetRefundHistory() error = %v", err)
+	}
+	if _, err := a.GetRefundHistory(context.TODO(), "1000"); err != nil || hits != 4 {
+		t.Errorf("GetRefundHistory() error = %v, hits = %d, want endpoints without a TTL uncached", err, hits)
+	}
+}
+
+func TestStoreClient_CacheSharedInvalidation(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"transactionId":"2000","originalTransactionId":"1000"}`))
+	arrived, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-release
+		w.Write([]byte(`{"signedTransactionInfo":"header.` + payload + `.signature"}`))
+	}))
+	defer srv.Close()
+
+	cache := NewLRUCache(LRUCacheConfig{})
+	c := newTestStoreConfig(t, srv.URL)
+	c.Cache = cache
+	c.CacheTTLs = map[Endpoint]time.Duration{EndpointGetTransactionInfo: time.Minute}
+	a, b := NewStoreClient(c), NewStoreClient(c)
+	c.CacheTTLs[EndpointGetTransactionInfo] = 0
+	if ttl := a.cacheTTL(EndpointGetTransactionInfo); ttl != time.Minute {
+		t.Errorf("cacheTTL() = %v after changing StoreConfig.CacheTTLs, want %v", ttl, time.Minute)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := a.GetTransactionInfo(context.TODO(), "2000")
+		done <- err
+	}()
+	<-arrived
+	b.InvalidateCache("1000")
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("GetTransactionInfo() error = %v", err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("cache Len() = %d, want the response fetched before another client's InvalidateCache not stored", cache.Len())
+	}
+}
@@ -0,0 +1,229 @@
+package appstore
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("appstore circuit breaker is open")
+
+// CircuitOpenError is returned without sending the request while the circuit of an endpoint is open.
+// It matches ErrCircuitOpen with errors.Is.
+type CircuitOpenError struct {
+	Endpoint Endpoint
+	RetryAt  time.Time // When the circuit lets a trial request through again.
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("appstore circuit breaker is open for %s until %s", e.Endpoint, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+type CircuitBreakerConfig struct {
+	FailureThreshold int                                            // Consecutive failures that open the circuit. Default is 5.
+	OpenTimeout      time.Duration                                  // How long the circuit stays open before a trial request. Default is 30 seconds.
+	HalfOpenRequests int                                            // Trial requests allowed at once while half-open. Default is 1.
+	SuccessThreshold int                                            // Successful trial requests that close the circuit. Default is 1.
+	IsFailure        func(resp *http.Response, err error) bool      // Default counts transport errors, timeouts and 5xx responses.
+	OnStateChange    func(endpoint Endpoint, from, to CircuitState) // Called after every transition, outside of any lock.
+	Clock            Clock                                          // Default is the system clock.
+}
+
+// CircuitBreaker stops sending requests to an endpoint after repeated failures, such as during an App Store outage
+// answering 5000000 and 5000001, and lets a trial request through once OpenTimeout has passed.
+// Each endpoint has its own circuit.
+type CircuitBreaker struct {
+	config   CircuitBreakerConfig
+	clock    Clock
+	mu       sync.Mutex
+	circuits map[Endpoint]*circuit
+}
+
+type circuit struct {
+	state     CircuitState
+	failures  int
+	successes int
+	inFlight  int // trial requests while half-open
+	openedAt  time.Time
+}
+
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = 5
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = 1
+	}
+	if config.IsFailure == nil {
+		config.IsFailure = isCircuitFailure
+	}
+	return &CircuitBreaker{
+		config:   config,
+		clock:    clockOrSystem(config.Clock),
+		circuits: make(map[Endpoint]*circuit),
+	}
+}
+
+func isCircuitFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// State returns the current state of the endpoint's circuit.
+func (cb *CircuitBreaker) State(endpoint Endpoint) CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	c, ok := cb.circuits[endpoint]
+	if !ok {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && !cb.clock.Now().Before(c.openedAt.Add(cb.config.OpenTimeout)) {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+type circuitTransition struct {
+	from, to CircuitState
+}
+
+func (cb *CircuitBreaker) notify(endpoint Endpoint, t *circuitTransition) {
+	if t != nil && cb.config.OnStateChange != nil {
+		cb.config.OnStateChange(endpoint, t.from, t.to)
+	}
+}
+
+func (cb *CircuitBreaker) setState(c *circuit, to CircuitState) *circuitTransition {
+	if c.state == to {
+		return nil
+	}
+	t := &circuitTransition{from: c.state, to: to}
+	c.state = to
+	c.failures, c.successes, c.inFlight = 0, 0, 0
+	if to == CircuitOpen {
+		c.openedAt = cb.clock.Now()
+	}
+	return t
+}
+
+// allow reserves a slot for a request, or returns a CircuitOpenError.
+func (cb *CircuitBreaker) allow(endpoint Endpoint) error {
+	cb.mu.Lock()
+	c, ok := cb.circuits[endpoint]
+	if !ok {
+		c = &circuit{}
+		cb.circuits[endpoint] = c
+	}
+
+	var t *circuitTransition
+	retryAt := c.openedAt.Add(cb.config.OpenTimeout)
+	if c.state == CircuitOpen && !cb.clock.Now().Before(retryAt) {
+		t = cb.setState(c, CircuitHalfOpen)
+	}
+
+	var err error
+	switch {
+	case c.state == CircuitOpen:
+		err = &CircuitOpenError{Endpoint: endpoint, RetryAt: retryAt}
+	case c.state == CircuitHalfOpen && c.inFlight >= cb.config.HalfOpenRequests:
+		err = &CircuitOpenError{Endpoint: endpoint, RetryAt: cb.clock.Now()}
+	case c.state == CircuitHalfOpen:
+		c.inFlight++
+	}
+	cb.mu.Unlock()
+
+	cb.notify(endpoint, t)
+	return err
+}
+
+// record updates the endpoint's circuit with the outcome of a request that allow let through.
+func (cb *CircuitBreaker) record(endpoint Endpoint, failed bool) {
+	cb.mu.Lock()
+	c := cb.circuits[endpoint]
+
+	var t *circuitTransition
+	switch c.state {
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+		} else if c.failures++; c.failures >= cb.config.FailureThreshold {
+			t = cb.setState(c, CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if c.inFlight > 0 {
+			c.inFlight--
+		}
+		if failed {
+			t = cb.setState(c, CircuitOpen)
+		} else if c.successes++; c.successes >= cb.config.SuccessThreshold {
+			t = cb.setState(c, CircuitClosed)
+		}
+	}
+	cb.mu.Unlock()
+
+	cb.notify(endpoint, t)
+}
+
+// release gives back a half-open slot for a request whose outcome says nothing about the endpoint.
+func (cb *CircuitBreaker) release(endpoint Endpoint) {
+	cb.mu.Lock()
+	if c := cb.circuits[endpoint]; c.state == CircuitHalfOpen && c.inFlight > 0 {
+		c.inFlight--
+	}
+	cb.mu.Unlock()
+}
+
+// SetCircuitBreaker fails fast with a CircuitOpenError while the circuit of the request's endpoint is open.
+// Chain it between SetRetry and RateLimit, as in SetRetry(SetCircuitBreaker(RateLimit(c, n), cb), bo, ShouldRetryDefault),
+// so rejected requests are not retried and use up no rate-limit capacity.
+func SetCircuitBreaker(c HTTPClient, cb *CircuitBreaker) DoFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		endpoint := EndpointFromRequest(req)
+		if err := cb.allow(endpoint); err != nil {
+			return nil, err
+		}
+
+		resp, err := c.Do(req)
+		if err != nil && req.Context().Err() != nil {
+			// The caller gave up, which tells nothing about the health of the endpoint.
+			cb.release(endpoint)
+			return resp, err
+		}
+		cb.record(endpoint, cb.config.IsFailure(resp, err))
+		return resp, err
+	}
+}
@@ -0,0 +1,67 @@
+package appstore
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	var transitions []string
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      10 * time.Second,
+		Clock:            clock,
+		OnStateChange: func(endpoint Endpoint, from, to CircuitState) {
+			transitions = append(transitions, string(endpoint)+":"+from.String()+"->"+to.String())
+		},
+	})
+
+	status := http.StatusInternalServerError
+	var calls int
+	c := SetCircuitBreaker(DoFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(`{"errorCode":5000000}`))}, nil
+	}), cb)
+
+	send := func(path string) error {
+		req, _ := http.NewRequest(http.MethodGet, HostProduction+path, nil)
+		_, err := c.Do(req)
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := send("/inApps/v1/transactions/1000"); err != nil {
+			t.Fatalf("Do() #%d error = %v", i, err)
+		}
+	}
+	err := send("/inApps/v1/transactions/1000")
+	var openErr *CircuitOpenError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &openErr) || openErr.Endpoint != EndpointGetTransactionInfo || calls != 2 {
+		t.Fatalf("Do() error = %v, calls = %d, want the open circuit to reject the request", err, calls)
+	}
+	if err = send("/inApps/v1/subscriptions/1000"); err != nil {
+		t.Errorf("Do() error = %v, want other endpoints unaffected", err)
+	}
+
+	clock.Advance(10 * time.Second)
+	if got := cb.State(EndpointGetTransactionInfo); got != CircuitHalfOpen {
+		t.Errorf("State() = %v, want half-open after OpenTimeout", got)
+	}
+	status = http.StatusOK
+	if err = send("/inApps/v1/transactions/1000"); err != nil {
+		t.Fatalf("Do() trial error = %v", err)
+	}
+	if got := cb.State(EndpointGetTransactionInfo); got != CircuitClosed {
+		t.Errorf("State() = %v, want closed after a successful trial", got)
+	}
+
+	want := []string{"GetTransactionInfo:closed->open", "GetTransactionInfo:open->half-open", "GetTransactionInfo:half-open->closed"}
+	if strings.Join(transitions, ",") != strings.Join(want, ",") {
+		t.Errorf("transitions = %v, want %v", transitions, want)
+	}
+}
@@ -0,0 +1,21 @@
+package appstore
+
+import "time"
+
+// Clock tells the current time. Rate limiting, backoff and token refresh take a Clock so tests can control time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func clockOrSystem(c Clock) Clock {
+	if c == nil {
+		return systemClock{}
+	}
+	return c
+}
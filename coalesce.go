@@ -0,0 +1,166 @@
+package appstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// coalescer shares one in-flight call between concurrent callers with the same key.
+// The shared call runs on a context detached from any single caller's cancellation. It is cancelled
+// once every caller waiting for it has given up, and its deadline is the latest among the callers',
+// none when one of them has no deadline.
+type coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+type coalescedCall struct {
+	done    chan struct{}
+	waiters int
+	ctx     *sharedContext
+
+	statusCode int
+	body       []byte
+	err        error
+}
+
+func newCoalescer() *coalescer {
+	return &coalescer{calls: make(map[string]*coalescedCall)}
+}
+
+func (g *coalescer) do(ctx context.Context, key string, fn func(context.Context) (int, []byte, error)) (int, []byte, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok || call.ctx.Err() != nil {
+		// A call past its deadline only lingers until fn returns; don't hand its stale error to a new caller.
+		call = &coalescedCall{done: make(chan struct{}), ctx: newSharedContext(ctx)}
+		g.calls[key] = call
+		go g.run(key, call, fn)
+	} else {
+		call.ctx.join(ctx)
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.statusCode, call.body, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		if call.waiters--; call.waiters == 0 {
+			call.ctx.cancel(context.Canceled)
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return 0, nil, ctx.Err()
+	}
+}
+
+func (g *coalescer) run(key string, call *coalescedCall, fn func(context.Context) (int, []byte, error)) {
+	call.statusCode, call.body, call.err = fn(call.ctx)
+	call.ctx.cancel(context.Canceled)
+
+	g.mu.Lock()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+	close(call.done)
+}
+
+// sharedContext is the context of a coalesced call. It keeps the values of the first caller's context,
+// and its deadline moves out as callers with later deadlines join.
+type sharedContext struct {
+	detachedContext
+
+	mu       sync.Mutex
+	deadline time.Time
+	bounded  bool // false once a caller without a deadline joined
+	timer    *time.Timer
+	done     chan struct{}
+	err      error
+}
+
+func newSharedContext(ctx context.Context) *sharedContext {
+	c := &sharedContext{detachedContext: detachedContext{ctx}, done: make(chan struct{})}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.deadline, c.bounded = deadline, true
+		// Hold the lock so a deadline that has already passed can't fire expire before timer is set.
+		c.mu.Lock()
+		c.timer = time.AfterFunc(time.Until(deadline), c.expire)
+		c.mu.Unlock()
+	}
+	return c
+}
+
+// join extends the deadline to cover the caller of ctx.
+func (c *sharedContext) join(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || !c.bounded {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		c.bounded = false
+		c.timer.Stop()
+		return
+	}
+	if deadline.After(c.deadline) {
+		c.deadline = deadline
+		c.timer.Reset(time.Until(deadline))
+	}
+}
+
+func (c *sharedContext) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The timer may fire just before join moved the deadline out.
+	if c.bounded && !time.Now().Before(c.deadline) {
+		c.cancelLocked(context.DeadlineExceeded)
+	}
+}
+
+func (c *sharedContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancelLocked(err)
+}
+
+func (c *sharedContext) cancelLocked(err error) {
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+func (c *sharedContext) Deadline() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, c.bounded
+}
+
+func (c *sharedContext) Done() <-chan struct{} { return c.done }
+
+func (c *sharedContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// detachedContext keeps the values of its parent but none of its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (c detachedContext) Value(key any) any         { return c.parent.Value(key) }
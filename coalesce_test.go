@@ -0,0 +1,211 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitForWaiters(t *testing.T, g *coalescer, key string, n int) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		g.mu.Lock()
+		call := g.calls[key]
+		joined := call != nil && call.waiters == n
+		g.mu.Unlock()
+		if joined {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d callers never joined %s", n, key)
+}
+
+func TestStoreClient_CoalesceReads(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte(`{"signedTransactionInfo":"jws"}`))
+	}))
+	defer srv.Close()
+
+	c := newTestStoreConfig(t, srv.URL)
+	c.CoalesceReads = true
+	a := NewStoreClient(c)
+
+	const callers = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rsp, err := a.GetTransactionInfo(context.TODO(), "1000")
+			if err == nil && rsp.SignedTransactionInfo != "jws" {
+				err = errors.New("unexpected response " + rsp.SignedTransactionInfo)
+			}
+			errs <- err
+		}()
+	}
+	waitForWaiters(t, a.coalescer, http.MethodGet+" "+srv.URL+"/inApps/v1/transactions/1000", callers)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetTransactionInfo() error = %v", err)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("server hits = %d, want 1", hits)
+	}
+}
+
+func TestCoalescer_Cancel(t *testing.T) {
+	g := newCoalescer()
+	started := make(chan context.Context, 1)
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, []byte, error) {
+		started <- ctx
+		select {
+		case <-release:
+			return http.StatusOK, []byte("ok"), nil
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		}
+	}
+
+	// One caller giving up leaves the shared call running for the other.
+	ctx1, cancel1 := context.WithCancel(context.TODO())
+	res1 := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(ctx1, "k", fn)
+		res1 <- err
+	}()
+	callCtx := <-started
+	res2 := make(chan []byte, 1)
+	go func() {
+		_, body, _ := g.do(context.TODO(), "k", fn)
+		res2 <- body
+	}()
+	waitForWaiters(t, g, "k", 2)
+	cancel1()
+	if err := <-res1; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller error = %v, want context.Canceled", err)
+	}
+	if callCtx.Err() != nil {
+		t.Fatalf("shared call cancelled while a caller still waits")
+	}
+	close(release)
+	if body := <-res2; string(body) != "ok" {
+		t.Errorf("remaining caller body = %q, want ok", body)
+	}
+
+	// The shared call is cancelled once every caller gave up.
+	ctx3, cancel3 := context.WithCancel(context.TODO())
+	release = make(chan struct{})
+	res3 := make(chan error, 1)
+	go func() {
+		_, _, err := g.do(ctx3, "k", fn)
+		res3 <- err
+	}()
+	callCtx = <-started
+	cancel3()
+	<-res3
+	select {
+	case <-callCtx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("shared call not cancelled after its only caller gave up")
+	}
+}
+
+func TestCoalescer_Deadline(t *testing.T) {
+	g := newCoalescer()
+	started := make(chan context.Context, 1)
+	fn := func(ctx context.Context) (int, []byte, error) {
+		started <- ctx
+		<-ctx.Done()
+		return 0, nil, ctx.Err()
+	}
+
+	// The shared call is bounded by the latest deadline among its callers, not left to run until cancelled.
+	short, cancelShort := context.WithTimeout(context.TODO(), 20*time.Millisecond)
+	defer cancelShort()
+	long, cancelLong := context.WithTimeout(context.TODO(), 80*time.Millisecond)
+	defer cancelLong()
+	res := make(chan error, 2)
+	go func() {
+		_, _, err := g.do(short, "k", fn)
+		res <- err
+	}()
+	callCtx := <-started
+	go func() {
+		_, _, err := g.do(long, "k", fn)
+		res <- err
+	}()
+	waitForWaiters(t, g, "k", 2)
+
+	longDeadline, _ := long.Deadline()
+	if deadline, ok := callCtx.Deadline(); !ok || !deadline.Equal(longDeadline) {
+		t.Errorf("shared call Deadline() = %v, %v, want the latest caller deadline %v", deadline, ok, longDeadline)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-res; !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("caller error = %v, want context.DeadlineExceeded", err)
+		}
+	}
+	select {
+	case <-callCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("shared call outlived every caller deadline")
+	}
+
+	// A caller without a deadline lifts it, since it is willing to wait for as long as the call takes.
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Hour)
+	defer cancel()
+	go g.do(ctx, "k2", fn)
+	callCtx = <-started
+	unbounded, cancelUnbounded := context.WithCancel(context.TODO())
+	go g.do(unbounded, "k2", fn)
+	waitForWaiters(t, g, "k2", 2)
+	if _, ok := callCtx.Deadline(); ok {
+		t.Errorf("shared call has a deadline after a caller without one joined")
+	}
+	cancel()
+	cancelUnbounded()
+	<-callCtx.Done()
+}
+
+func TestCoalescer_ExpiredCall(t *testing.T) {
+	g := newCoalescer()
+	var calls int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (int, []byte, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// The first call ignores its deadline for a while, like a request stuck in a slow transport.
+			<-release
+			return 0, nil, ctx.Err()
+		}
+		return http.StatusOK, []byte("ok"), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := g.do(ctx, "k", fn); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("first caller error = %v, want context.DeadlineExceeded", err)
+	}
+	defer close(release)
+
+	later, cancelLater := context.WithTimeout(context.TODO(), time.Second)
+	defer cancelLater()
+	if _, body, err := g.do(later, "k", fn); err != nil || string(body) != "ok" {
+		t.Errorf("later caller = %q, %v, want a fresh call instead of the expired one", body, err)
+	}
+}
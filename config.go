@@ -0,0 +1,136 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrKeyIDRequired      = errors.New("appstore config: KeyID is required")
+	ErrInvalidBundleID    = errors.New("appstore config: invalid BundleID")
+	ErrInvalidIssuer      = errors.New("appstore config: Issuer must be a UUID")
+	ErrInvalidEnvironment = errors.New("appstore config: invalid Environment")
+	ErrInvalidBaseURL     = errors.New("appstore config: invalid BaseURL")
+	ErrBaseURLRequired    = errors.New("appstore config: BaseURL is required")
+)
+
+// ConfigError lists every problem Validate found in a StoreConfig.
+type ConfigError struct {
+	Errs []error
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ConfigError) Unwrap() []error {
+	return e.Errs
+}
+
+// Is matches any of the problems, for Go versions whose errors.Is doesn't follow Unwrap() []error.
+func (e *ConfigError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the config for mistakes that would only surface on the first API call.
+// It parses KeyContent unless a Signer or KeyProvider signs instead, and reports every problem in a *ConfigError.
+func (c *StoreConfig) Validate() error {
+	var errs []error
+	if c.KeyProvider == nil {
+		if c.KeyID == "" {
+			errs = append(errs, ErrKeyIDRequired)
+		}
+		if c.Signer == nil {
+			if _, err := (&Token{}).passKeyFromByte(c.KeyContent); err != nil {
+				errs = append(errs, fmt.Errorf("KeyContent: %w", err))
+			}
+		}
+	}
+	if c.BundleID == "" || strings.IndexFunc(c.BundleID, unicode.IsSpace) >= 0 {
+		errs = append(errs, fmt.Errorf("%w %q", ErrInvalidBundleID, c.BundleID))
+	}
+	if err := validateKeyType(c.KeyType, c.Issuer); err != nil {
+		errs = append(errs, err)
+	} else if c.Issuer != "" {
+		if _, err := uuid.Parse(c.Issuer); err != nil || len(c.Issuer) != 36 {
+			errs = append(errs, fmt.Errorf("%w, got %q", ErrInvalidIssuer, c.Issuer))
+		}
+	}
+	switch c.Environment {
+	case "", Production, Sandbox:
+	case LocalTesting:
+		if c.BaseURL == "" {
+			errs = append(errs, fmt.Errorf("%w for the %s environment, which has no public host", ErrBaseURLRequired, c.Environment))
+		}
+	case Xcode:
+		// Xcode signs StoreKit test transactions locally; there is no App Store Server API to call.
+		errs = append(errs, fmt.Errorf("%w %q: the App Store Server API has no Xcode environment", ErrInvalidEnvironment, c.Environment))
+	default:
+		errs = append(errs, fmt.Errorf("%w %q", ErrInvalidEnvironment, c.Environment))
+	}
+	if c.BaseURL != "" {
+		if u, err := url.Parse(c.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%w %q", ErrInvalidBaseURL, c.BaseURL))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ConfigError{Errs: errs}
+	}
+	return nil
+}
+
+// NewValidatedStoreClient is NewStoreClient that validates config and signs a first token before returning,
+// so a broken key or config fails at startup rather than on the first API call.
+func NewValidatedStoreClient(config *StoreConfig) (*StoreClient, error) {
+	return NewValidatedStoreClientWithHTTPClient(config, &http.Client{
+		Timeout: 30 * time.Second,
+	})
+}
+
+// NewValidatedStoreClientWithHTTPClient is NewStoreClientWithHTTPClient with the checks of NewValidatedStoreClient.
+func NewValidatedStoreClientWithHTTPClient(config *StoreConfig, httpClient HTTPClient) (*StoreClient, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	client := NewStoreClientWithHTTPClient(config, httpClient)
+	if _, err := client.Token.GenerateIfExpired(); err != nil {
+		return nil, &ConfigError{Errs: []error{fmt.Errorf("generate token: %w", err)}}
+	}
+	return client, nil
+}
+
+// CheckCredentials asks the App Store whether it accepts the client's token, such as for a readiness probe.
+// It looks up a transaction that doesn't exist, so only a transaction id not found error proves the
+// credentials work for the app. The probe bypasses the cache, and a 401 neither refreshes the token
+// nor reports the key to the KeyProvider, so checking doesn't change which key the client signs with.
+func (c *StoreClient) CheckCredentials(ctx context.Context) error {
+	_, _, err := execute[TransactionInfoResponse](ctx, c, &apiRequest{
+		endpoint:   EndpointGetTransactionInfo,
+		method:     http.MethodGet,
+		path:       PathTransactionInfo,
+		pathParams: map[string]string{"transactionId": "0"},
+		probe:      true,
+	})
+	if err == nil || errors.Is(err, TransactionIdNotFoundError) {
+		return nil
+	}
+	return err
+}
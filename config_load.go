@@ -0,0 +1,134 @@
+package appstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// configDocument is the JSON form of a StoreConfig. Key holds the .p8 content inline, KeyFile its path.
+type configDocument struct {
+	KeyID       string      `json:"keyId"`
+	Key         string      `json:"key,omitempty"`
+	KeyFile     string      `json:"keyFile,omitempty"`
+	BundleID    string      `json:"bundleId"`
+	Issuer      string      `json:"issuer,omitempty"`
+	KeyType     KeyType     `json:"keyType,omitempty"`
+	Audience    string      `json:"audience,omitempty"`
+	Environment Environment `json:"environment,omitempty"`
+	BaseURL     string      `json:"baseUrl,omitempty"`
+}
+
+func (d *configDocument) storeConfig() (*StoreConfig, error) {
+	c := &StoreConfig{
+		KeyContent:  []byte(d.Key),
+		KeyID:       d.KeyID,
+		BundleID:    d.BundleID,
+		Issuer:      d.Issuer,
+		KeyType:     d.KeyType,
+		Audience:    d.Audience,
+		Environment: d.Environment,
+		BaseURL:     d.BaseURL,
+	}
+	if d.Key == "" && d.KeyFile != "" {
+		key, err := ReadKeyFile(d.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.KeyContent = key
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReadKeyFile reads the .p8 private key downloaded from App Store Connect, for StoreConfig.KeyContent.
+func ReadKeyFile(path string) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("appstore config: read key file err %w", err)
+	}
+	if _, err = (&Token{}).passKeyFromByte(key); err != nil {
+		return nil, fmt.Errorf("appstore config: key file %s err %w", path, err)
+	}
+	return key, nil
+}
+
+// LookupEnvFunc reads an environment variable the way os.LookupEnv does.
+type LookupEnvFunc func(key string) (string, bool)
+
+// LoadStoreConfigFromEnv builds a StoreConfig from the variables below, each name preceded by prefix
+// (Ex: "APPSTORE_"). KEY holds the .p8 content and KEY_FILE its path. lookup defaults to os.LookupEnv;
+// pass a map-backed function in tests.
+//
+//	KEY_ID, KEY, KEY_FILE, BUNDLE_ID, ISSUER, KEY_TYPE, AUDIENCE, ENVIRONMENT, BASE_URL
+func LoadStoreConfigFromEnv(prefix string, lookup LookupEnvFunc) (*StoreConfig, error) {
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	get := func(name string) string {
+		v, _ := lookup(prefix + name)
+		return strings.TrimSpace(v)
+	}
+	key := get("KEY")
+	if !strings.Contains(key, "\n") {
+		// Secrets often store the PEM on one line with escaped newlines.
+		key = strings.Replace(key, `\n`, "\n", -1)
+	}
+	d := &configDocument{
+		KeyID:       get("KEY_ID"),
+		Key:         key,
+		KeyFile:     get("KEY_FILE"),
+		BundleID:    get("BUNDLE_ID"),
+		Issuer:      get("ISSUER"),
+		KeyType:     KeyType(get("KEY_TYPE")),
+		Audience:    get("AUDIENCE"),
+		Environment: Environment(get("ENVIRONMENT")),
+		BaseURL:     get("BASE_URL"),
+	}
+	return d.storeConfig()
+}
+
+// LoadStoreConfigJSON builds a StoreConfig from a JSON document such as
+//
+//	{"keyId": "2X9R4HXF34", "keyFile": "/run/secrets/AuthKey.p8", "bundleId": "com.example", "issuer": "57246542-96fe-1a63-e053-0824d011072a"}
+//
+// The key is either inline in "key" or read from "keyFile".
+func LoadStoreConfigJSON(r io.Reader) (*StoreConfig, error) {
+	var d configDocument
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&d); err != nil {
+		return nil, fmt.Errorf("appstore config: decode json err %w", err)
+	}
+	return d.storeConfig()
+}
+
+// String describes the config with its secrets redacted, so it is safe to log.
+func (c StoreConfig) String() string {
+	return fmt.Sprintf("StoreConfig{KeyID: %s, BundleID: %s, Issuer: %s, KeyType: %s, Environment: %s, BaseURL: %s, KeyContent: %s, Signer: %s}",
+		c.KeyID, c.BundleID, c.Issuer, c.KeyType, c.environment(), c.hostURL(), redactBytes(c.KeyContent), redactSet(c.Signer != nil || c.KeyProvider != nil))
+}
+
+// GoString redacts secrets from %#v as well.
+func (c StoreConfig) GoString() string {
+	return fmt.Sprintf("appstore.StoreConfig{KeyID:%q, BundleID:%q, Issuer:%q, KeyType:%q, Environment:%q, BaseURL:%q, KeyContent:%q}",
+		c.KeyID, c.BundleID, c.Issuer, c.KeyType, c.Environment, c.BaseURL, redactBytes(c.KeyContent))
+}
+
+func redactBytes(b []byte) string {
+	if len(b) == 0 {
+		return "<empty>"
+	}
+	return fmt.Sprintf("REDACTED(%d bytes)", len(b))
+}
+
+func redactSet(set bool) string {
+	if set {
+		return "REDACTED"
+	}
+	return "<nil>"
+}
@@ -0,0 +1,140 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStoreConfig_Validate(t *testing.T) {
+	config := &StoreConfig{
+		KeyContent:  []byte("not a pem"),
+		BundleID:    "fake.bundle.id ",
+		Issuer:      "issuer",
+		Environment: "Staging",
+		BaseURL:     "localhost:8080",
+	}
+	err := config.Validate()
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || len(configErr.Errs) != 6 {
+		t.Fatalf("Validate() error = %v, want six problems", err)
+	}
+	for _, want := range []error{ErrAuthKeyInvalidPem, ErrKeyIDRequired, ErrInvalidBundleID, ErrInvalidIssuer, ErrInvalidEnvironment, ErrInvalidBaseURL} {
+		if !errors.Is(err, want) {
+			t.Errorf("Validate() error = %v, want it to match %v", err, want)
+		}
+	}
+
+	for env, want := range map[Environment]error{LocalTesting: ErrBaseURLRequired, Xcode: ErrInvalidEnvironment} {
+		c := newTestStoreConfig(t, "")
+		c.Environment = env
+		if err := c.Validate(); !errors.Is(err, want) {
+			t.Errorf("Validate() with %s error = %v, want %v", env, err, want)
+		}
+	}
+	c := newTestStoreConfig(t, "")
+	c.Environment = LocalTesting
+	if _, err := NewStoreClient(c).GetTransactionInfo(context.TODO(), "1000"); !errors.Is(err, ErrBaseURLRequired) {
+		t.Errorf("GetTransactionInfo() error = %v, want %v without a request", err, ErrBaseURLRequired)
+	}
+
+	if _, err = NewValidatedStoreClient(config); err == nil {
+		t.Errorf("NewValidatedStoreClient() succeeded with an invalid config")
+	}
+	if _, err = NewValidatedStoreClient(newTestStoreConfig(t, "")); err != nil {
+		t.Errorf("NewValidatedStoreClient() error = %v", err)
+	}
+}
+
+func TestStoreClient_CheckCredentials(t *testing.T) {
+	status, body := http.StatusNotFound, `{"errorCode":4040010,"errorMessage":"Transaction id not found."}`
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	a, err := NewValidatedStoreClient(newTestStoreConfig(t, srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.CheckCredentials(context.TODO()); err != nil {
+		t.Errorf("CheckCredentials() error = %v, want nil when only the transaction is unknown", err)
+	}
+
+	status, body = http.StatusNotFound, `{"errorCode":4040003,"errorMessage":"App not found."}`
+	if err = a.CheckCredentials(context.TODO()); !errors.Is(err, AppNotFoundError) {
+		t.Errorf("CheckCredentials() error = %v, want AppNotFoundError", err)
+	}
+
+	status, body = http.StatusUnauthorized, ""
+	bearer, _ := a.Token.GenerateIfExpired()
+	atomic.StoreInt32(&hits, 0)
+	if err = a.CheckCredentials(context.TODO()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("CheckCredentials() error = %v, want ErrUnauthorized", err)
+	}
+	if after, _ := a.Token.GenerateIfExpired(); hits != 1 || after != bearer {
+		t.Errorf("CheckCredentials() sent %d requests and changed the token %v, want one request and no refresh", hits, after != bearer)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err = a.CheckCredentials(context.TODO()); err == nil {
+		t.Errorf("CheckCredentials() error = nil while the App Store is unavailable")
+	}
+}
+
+func TestLoadStoreConfig(t *testing.T) {
+	key := newTestKeyContent(t)
+	keyFile := filepath.Join(t.TempDir(), "AuthKey.p8")
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"APPSTORE_KEY_ID":      "2X9R4HXF34",
+		"APPSTORE_KEY":         strings.Replace(string(key), "\n", `\n`, -1),
+		"APPSTORE_BUNDLE_ID":   "fake.bundle.id",
+		"APPSTORE_ISSUER":      "57246542-96fe-1a63-e053-0824d011072a",
+		"APPSTORE_ENVIRONMENT": "Sandbox",
+	}
+	lookup := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+	c, err := LoadStoreConfigFromEnv("APPSTORE_", lookup)
+	if err != nil {
+		t.Fatalf("LoadStoreConfigFromEnv() error = %v", err)
+	}
+	if c.KeyID != "2X9R4HXF34" || c.Environment != Sandbox || string(c.KeyContent) != string(key) {
+		t.Errorf("LoadStoreConfigFromEnv() = %v", c)
+	}
+
+	delete(env, "APPSTORE_KEY")
+	env["APPSTORE_KEY_FILE"] = keyFile
+	if c, err = LoadStoreConfigFromEnv("APPSTORE_", lookup); err != nil || string(c.KeyContent) != string(key) {
+		t.Errorf("LoadStoreConfigFromEnv() with KEY_FILE error = %v", err)
+	}
+	if _, err = LoadStoreConfigFromEnv("OTHER_", lookup); !errors.Is(err, ErrKeyIDRequired) {
+		t.Errorf("LoadStoreConfigFromEnv() error = %v, want ErrKeyIDRequired", err)
+	}
+
+	doc := `{"keyId": "2X9R4HXF34", "keyFile": "` + keyFile + `", "bundleId": "fake.bundle.id", "keyType": "individual"}`
+	if c, err = LoadStoreConfigJSON(strings.NewReader(doc)); err != nil || c.KeyType != IndividualKey {
+		t.Errorf("LoadStoreConfigJSON() = %v, %v", c, err)
+	}
+
+	for _, s := range []string{c.String(), fmt.Sprintf("%v", *c), fmt.Sprintf("%#v", *c)} {
+		if strings.Contains(s, "PRIVATE KEY") || !strings.Contains(s, "REDACTED") {
+			t.Errorf("formatted config %q leaks the key", s)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"net/url"
+)
+
+// DualStoreClient calls the production environment first and retries in sandbox when the
+// transaction isn't found there, since TestFlight and App Review purchases only exist in sandbox.
+// Doc: https://developer.apple.com/documentation/appstoreserverapi
+type DualStoreClient struct {
+	Production *StoreClient
+	Sandbox    *StoreClient
+}
+
+// NewDualStoreClient creates a production and a sandbox client from the same config.
+// The Environment, Sandbox and BaseURL fields of config are ignored. Apple grants each environment its own
+// quota, so the sandbox client gets its own copy of config.RateLimiter. Both clients share config.Cache,
+// where their entries are kept apart by environment.
+func NewDualStoreClient(config *StoreConfig) *DualStoreClient {
+	prod, sandbox := dualConfigs(config)
+	return &DualStoreClient{
+		Production: NewStoreClient(prod),
+		Sandbox:    NewStoreClient(sandbox),
+	}
+}
+
+// NewDualStoreClientWithHTTPClient creates a dual environment client that shares a custom http client.
+func NewDualStoreClientWithHTTPClient(config *StoreConfig, httpClient HTTPClient) *DualStoreClient {
+	prod, sandbox := dualConfigs(config)
+	return &DualStoreClient{
+		Production: NewStoreClientWithHTTPClient(prod, httpClient),
+		Sandbox:    NewStoreClientWithHTTPClient(sandbox, httpClient),
+	}
+}
+
+func dualConfigs(config *StoreConfig) (*StoreConfig, *StoreConfig) {
+	prod, sandbox := *config, *config
+	prod.Environment, prod.Sandbox, prod.BaseURL = Production, false, ""
+	sandbox.Environment, sandbox.Sandbox, sandbox.BaseURL = Sandbox, true, ""
+	if config.RateLimiter != nil {
+		sandbox.RateLimiter = config.RateLimiter.clone()
+	}
+	return &prod, &sandbox
+}
+
+// shouldFallbackToSandbox reports whether a production error means the lookup should be repeated in sandbox.
+func shouldFallbackToSandbox(err error) bool {
+	return errors.Is(err, TransactionIdNotFoundError) || errors.Is(err, OriginalTransactionIdNotFoundError)
+}
+
+func withSandboxFallback[T any](d *DualStoreClient, call func(*StoreClient) (T, error)) (T, Environment, error) {
+	rsp, err := call(d.Production)
+	if err == nil || !shouldFallbackToSandbox(err) {
+		return rsp, Production, err
+	}
+	rsp, err = call(d.Sandbox)
+	return rsp, Sandbox, err
+}
+
+// GetTransactionInfo https://developer.apple.com/documentation/appstoreserverapi/get_transaction_info
+func (d *DualStoreClient) GetTransactionInfo(ctx context.Context, transactionId string) (*TransactionInfoResponse, Environment, error) {
+	return withSandboxFallback(d, func(c *StoreClient) (*TransactionInfoResponse, error) {
+		return c.GetTransactionInfo(ctx, transactionId)
+	})
+}
+
+// GetTransactionHistory https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
+func (d *DualStoreClient) GetTransactionHistory(ctx context.Context, originalTransactionId string, query *url.Values) ([]*HistoryResponse, Environment, error) {
+	return withSandboxFallback(d, func(c *StoreClient) ([]*HistoryResponse, error) {
+		// GetTransactionHistory advances the revision in query while paging, so each environment gets its own copy.
+		var q *url.Values
+		if query != nil {
+			cp := cloneValues(*query)
+			q = &cp
+		}
+		return c.GetTransactionHistory(ctx, originalTransactionId, q)
+	})
+}
+
+// GetALLSubscriptionStatuses https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses
+func (d *DualStoreClient) GetALLSubscriptionStatuses(ctx context.Context, originalTransactionId string) (*StatusResponse, Environment, error) {
+	return withSandboxFallback(d, func(c *StoreClient) (*StatusResponse, error) {
+		return c.GetALLSubscriptionStatuses(ctx, originalTransactionId)
+	})
+}
+
+// GetRefundHistory https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
+func (d *DualStoreClient) GetRefundHistory(ctx context.Context, originalTransactionId string) ([]*RefundLookupResponse, Environment, error) {
+	return withSandboxFallback(d, func(c *StoreClient) ([]*RefundLookupResponse, error) {
+		return c.GetRefundHistory(ctx, originalTransactionId)
+	})
+}
+
+// LookupOrderID https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
+func (d *DualStoreClient) LookupOrderID(ctx context.Context, orderId string) (*OrderLookupResponse, Environment, error) {
+	return withSandboxFallback(d, func(c *StoreClient) (*OrderLookupResponse, error) {
+		return c.LookupOrderID(ctx, orderId)
+	})
+}
+
+// InvalidateCache drops the cached responses involving originalTransactionId in both environments.
+func (d *DualStoreClient) InvalidateCache(originalTransactionId string) {
+	d.Production.InvalidateCache(originalTransactionId)
+	d.Sandbox.InvalidateCache(originalTransactionId)
+}
+
+func cloneValues(v url.Values) url.Values {
+	cp := make(url.Values, len(v))
+	for k, vs := range v {
+		cp[k] = append([]string(nil), vs...)
+	}
+	return cp
+}
@@ -0,0 +1,60 @@
+package appstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDualStoreClient_GetTransactionInfo(t *testing.T) {
+	var hosts []string
+	hc := DoFunc(func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Scheme+"://"+req.URL.Host)
+		rsp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{"signedTransactionInfo":"jws"}`))}
+		if req.URL.Host == strings.TrimPrefix(HostProduction, "https://") {
+			rsp.StatusCode = http.StatusNotFound
+			rsp.Body = io.NopCloser(strings.NewReader(`{"errorCode":4040010,"errorMessage":"Transaction id not found."}`))
+		}
+		return rsp, nil
+	})
+
+	d := NewDualStoreClientWithHTTPClient(newTestStoreConfig(t, ""), hc)
+	rsp, env, err := d.GetTransactionInfo(context.TODO(), "2000000000000001")
+	if err != nil {
+		t.Fatalf("GetTransactionInfo() error = %v", err)
+	}
+	if env != Sandbox || rsp.SignedTransactionInfo != "jws" {
+		t.Errorf("GetTransactionInfo() rsp = %v, env = %v", rsp, env)
+	}
+	if len(hosts) != 2 || hosts[0] != HostProduction || hosts[1] != HostSandBox {
+		t.Errorf("requested hosts = %v", hosts)
+	}
+}
+
+func TestDualStoreClient_SharedConfig(t *testing.T) {
+	var requests int
+	hc := DoFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: io.NopCloser(strings.NewReader(`{"signedTransactionInfo":"jws"}`))}, nil
+	})
+	config := newTestStoreConfig(t, "")
+	config.Cache = NewLRUCache(LRUCacheConfig{})
+	config.RateLimiter = NewRateLimiter(RateLimiterConfig{Limits: map[Endpoint]Rate{EndpointGetTransactionInfo: {Requests: 60, Per: time.Hour, Burst: 1}}})
+	d := NewDualStoreClientWithHTTPClient(config, hc)
+
+	// A sandbox lookup neither spends the production quota nor answers a production lookup from the cache.
+	for _, c := range []*StoreClient{d.Sandbox, d.Production} {
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+		_, err := c.GetTransactionInfo(ctx, "1000")
+		cancel()
+		if err != nil {
+			t.Fatalf("%s GetTransactionInfo() error = %v", c.Environment(), err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("sent %d requests, want one per environment", requests)
+	}
+}
@@ -7,15 +7,26 @@ import (
 	"strconv"
 )
 
+// Error is an error response of the App Store Server API. The sentinel values below only carry an errorCode
+// and errorMessage, and errors.Is matches a response against them by errorCode.
 type Error struct {
 	errorCode    int
 	errorMessage string
 
-	// retryAfter is the number of seconds after which the client can retry the request.
+	// retryAfter is the UNIX time, in milliseconds, after which the client can retry the request.
 	// This field is only set to the `Retry-After` header if you receive the HTTP 429 error, that informs you when you can next send a request.
 	retryAfter int64
+
+	httpStatus int
+	endpoint   Endpoint
+	header     http.Header
+	rawBody    []byte
+	sentinel   *Error // registered error of errorCode, nil for the sentinels themselves and unknown codes
 }
 
+// requestIDHeaders are the response headers Apple identifies a request with, in order of preference.
+var requestIDHeaders = []string{"X-Apple-Request-Uuid", "X-Apple-Jingle-Correlation-Key", "X-Request-Id"}
+
 func newError(errorCode int, errorMessage string) *Error {
 	return &Error{
 		errorCode:    errorCode,
@@ -28,6 +39,19 @@ type appStoreAPIErrorResp struct {
 	ErrorMessage string `json:"errorMessage"`
 }
 
+// newResponseError describes a non-2xx response, decoding Apple's errorCode from the body when it has one.
+func newResponseError(endpoint Endpoint, statusCode int, hd http.Header, b []byte) *Error {
+	e, ok := newAppStoreAPIError(b, hd)
+	if !ok {
+		e = &Error{errorMessage: http.StatusText(statusCode)}
+	}
+	e.httpStatus = statusCode
+	e.endpoint = endpoint
+	e.header = hd
+	e.rawBody = b
+	return e
+}
+
 func newAppStoreAPIError(b []byte, hd http.Header) (*Error, bool) {
 	if len(b) == 0 {
 		return nil, false
@@ -39,16 +63,31 @@ func newAppStoreAPIError(b []byte, hd http.Header) (*Error, bool) {
 	if rErr.ErrorCode == 0 {
 		return nil, false
 	}
-	if rErr.ErrorCode == 4290000 {
-		retryAfter, err := strconv.ParseInt(hd.Get("Retry-After"), 10, 64)
-		if err == nil {
-			return &Error{errorCode: rErr.ErrorCode, errorMessage: rErr.ErrorMessage, retryAfter: retryAfter}, true
+	return newErrorFromCode(rErr.ErrorCode, rErr.ErrorMessage, hd), true
+}
+
+// newErrorFromCode builds the error of a response around the sentinel registered for code,
+// falling back to the sentinel's message when the response has none.
+func newErrorFromCode(code int, message string, hd http.Header) *Error {
+	e := &Error{errorCode: code, errorMessage: message}
+	if sentinel, ok := ErrorFromCode(code); ok {
+		e.sentinel = sentinel
+		if e.errorMessage == "" {
+			e.errorMessage = sentinel.errorMessage
 		}
 	}
-	return &Error{errorCode: rErr.ErrorCode, errorMessage: rErr.ErrorMessage}, true
+	if code == RateLimitExceededError.errorCode {
+		if retryAfter, err := strconv.ParseInt(hd.Get("Retry-After"), 10, 64); err == nil {
+			e.retryAfter = retryAfter
+		}
+	}
+	return e
 }
 
 func (e *Error) Error() string {
+	if e.errorCode == 0 && e.httpStatus != 0 {
+		return fmt.Sprintf("appstore api: %s return status code %d", e.endpoint, e.httpStatus)
+	}
 	return fmt.Sprintf("errorCode: %d, errorMessage: %s", e.errorCode, e.errorMessage)
 }
 
@@ -61,7 +100,7 @@ func (e *Error) As(target interface{}) bool {
 }
 
 func (e *Error) Is(target error) bool {
-	if other, ok := target.(*Error); ok && other.errorCode == e.errorCode {
+	if other, ok := target.(*Error); ok && other.errorCode != 0 && other.errorCode == e.errorCode {
 		return true
 	}
 	return false
@@ -79,6 +118,84 @@ func (e *Error) RetryAfter() int64 {
 	return e.retryAfter
 }
 
+// Unwrap returns the sentinel registered for the errorCode, so errors.Is works with == comparisons too.
+func (e *Error) Unwrap() error {
+	if e.sentinel == nil {
+		return nil
+	}
+	return e.sentinel
+}
+
+// Category returns how to react to the error, derived from its errorCode.
+func (e *Error) Category() ErrorCategory {
+	if e.errorCode == 0 {
+		switch {
+		case e.httpStatus == http.StatusTooManyRequests:
+			return ErrorCategoryRateLimit
+		case e.httpStatus == http.StatusNotFound:
+			return ErrorCategoryNotFound
+		case e.httpStatus >= 500:
+			return ErrorCategoryServer
+		}
+	}
+	return categoryOf(e.errorCode)
+}
+
+// DocURL returns the documentation page of the errorCode, empty for undocumented codes.
+func (e *Error) DocURL() string {
+	if info, ok := errorRegistry[e.errorCode]; ok {
+		return info.DocURL
+	}
+	return ""
+}
+
+// HTTPStatus returns the status code of the response, 0 for the sentinel values.
+func (e *Error) HTTPStatus() int {
+	return e.httpStatus
+}
+
+// Endpoint returns the endpoint that answered with the error.
+func (e *Error) Endpoint() Endpoint {
+	return e.endpoint
+}
+
+// Header returns the headers of the response.
+func (e *Error) Header() http.Header {
+	return e.header
+}
+
+// RawBody returns the body of the response as received, which helps with responses that aren't JSON.
+func (e *Error) RawBody() []byte {
+	return e.rawBody
+}
+
+// RequestID returns the identifier Apple assigned to the request, to quote when contacting Apple support.
+// It is empty when the response carries none.
+func (e *Error) RequestID() string {
+	for _, k := range requestIDHeaders {
+		if v := e.header.Get(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// IsRetryable reports whether the App Store asks to try the request again, either with a retryable
+// errorCode, a rate limit or a server error without an errorCode.
+func (e *Error) IsRetryable() bool {
+	return retryableErrorCodes[e.errorCode] || e.IsRateLimited() || (e.errorCode == 0 && e.httpStatus >= 500)
+}
+
+// IsNotFound reports whether the account, app, transaction or resource of the request was not found.
+func (e *Error) IsNotFound() bool {
+	return e.httpStatus == http.StatusNotFound || e.errorCode/10000 == 404
+}
+
+// IsRateLimited reports whether the request exceeded the App Store Server API rate limit.
+func (e *Error) IsRateLimited() bool {
+	return e.errorCode == RateLimitExceededError.errorCode || e.httpStatus == http.StatusTooManyRequests
+}
+
 var (
 	// Retryable errors
 	AccountNotFoundRetryableError               = newError(4040002, "Account not found. Please try again.")
@@ -99,6 +216,7 @@ var (
 	InvalidRequestIdentifierError                    = newError(4000011, "Invalid request identifier.")
 	InvalidRequestRevisionError                      = newError(4000005, "Invalid request revision.")
 	InvalidRevokedError                              = newError(4000030, "Invalid request. The revoked parameter is invalid.")
+	InvalidExcludeRevokedError                       = newError(4000025, "Invalid request. The exclude revoked parameter is invalid.")
 	InvalidStatusError                               = newError(4000031, "Invalid request. The status parameter is invalid.")
 	InvalidStorefrontCountryCodeError                = newError(4000028, "Invalid request. A storefront country code was invalid.")
 	InvalidTransactionIdError                        = newError(4000006, "Invalid transaction id.")
@@ -137,6 +255,7 @@ var (
 	InvalidSampleContentProvidedError            = newError(4000041, "Invalid request. The sample content provided field is invalid")
 	InvalidUserStatusError                       = newError(4000042, "Invalid request. The user status field is invalid")
 	InvalidTransactionNotConsumableError         = newError(4000043, "Invalid request. The transaction id parameter must represent a consumable in-app purchase")
+	InvalidRefundPreferenceError                 = newError(4000044, "Invalid request. The refund preference field is invalid")
 	InvalidTransactionTypeNotSupportedError      = newError(4000047, "Invalid request. The transaction id doesn't represent a supported in-app purchase type")
 	AppTransactionIdNotSupportedError            = newError(4000048, "Invalid request. Invalid request. App transactions aren't supported by this endpoint")
 	InvalidAppAccountTokenUUIDError              = newError(4000183, "Invalid request. The app account token field must be a valid UUID")
@@ -0,0 +1,88 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStoreClient_ResponseError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Apple-Request-Uuid", "req-1")
+		switch r.URL.Path {
+		case "/inApps/v1/transactions/404":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorCode":4040010,"errorMessage":"Transaction id not found."}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html>Bad Gateway</html>`))
+		}
+	}))
+	defer srv.Close()
+
+	a := NewStoreClient(newTestStoreConfig(t, srv.URL))
+
+	_, err := a.GetTransactionInfo(context.TODO(), "404")
+	wrapped := fmt.Errorf("lookup: %w", err)
+	if !errors.Is(wrapped, TransactionIdNotFoundError) || errors.Is(wrapped, OriginalTransactionIdNotFoundError) {
+		t.Fatalf("GetTransactionInfo() error = %v, want TransactionIdNotFoundError", err)
+	}
+	var apiErr *Error
+	if !errors.As(wrapped, &apiErr) {
+		t.Fatalf("errors.As(%v) failed", wrapped)
+	}
+	if apiErr.HTTPStatus() != http.StatusNotFound || apiErr.Endpoint() != EndpointGetTransactionInfo || apiErr.RequestID() != "req-1" {
+		t.Errorf("Error status = %d, endpoint = %s, request id = %q", apiErr.HTTPStatus(), apiErr.Endpoint(), apiErr.RequestID())
+	}
+	if !apiErr.IsNotFound() || apiErr.IsRetryable() || apiErr.IsRateLimited() {
+		t.Errorf("Error classified as not found %v, retryable %v, rate limited %v", apiErr.IsNotFound(), apiErr.IsRetryable(), apiErr.IsRateLimited())
+	}
+
+	_, err = a.GetTransactionInfo(context.TODO(), "502")
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetTransactionInfo() error = %v, want an *Error for a non-JSON body", err)
+	}
+	if apiErr.HTTPStatus() != http.StatusBadGateway || string(apiErr.RawBody()) != "<html>Bad Gateway</html>" || !apiErr.IsRetryable() {
+		t.Errorf("Error status = %d, body = %q, retryable = %v", apiErr.HTTPStatus(), apiErr.RawBody(), apiErr.IsRetryable())
+	}
+	if errors.Is(err, GeneralInternalError) {
+		t.Errorf("errors.Is(%v, GeneralInternalError) = true, want no match without an errorCode", err)
+	}
+
+	if !RateLimitExceededError.IsRateLimited() || !GeneralInternalRetryableError.IsRetryable() || !AccountNotFoundError.IsNotFound() {
+		t.Errorf("sentinel errors are misclassified")
+	}
+}
+
+func TestErrorFromCode(t *testing.T) {
+	for _, tt := range []struct {
+		code     int
+		want     *Error
+		category ErrorCategory
+	}{
+		{4040010, TransactionIdNotFoundError, ErrorCategoryNotFound},
+		{4040006, OriginalTransactionIdNotFoundRetryableError, ErrorCategoryRetryable},
+		{4290000, RateLimitExceededError, ErrorCategoryRateLimit},
+		{5000000, GeneralInternalError, ErrorCategoryServer},
+		{4000044, InvalidRefundPreferenceError, ErrorCategoryClient},
+	} {
+		got, ok := ErrorFromCode(tt.code)
+		if !ok || got != tt.want || got.Category() != tt.category {
+			t.Errorf("ErrorFromCode(%d) = %v, %v, category %s", tt.code, got, ok, got.Category())
+		}
+	}
+	if _, ok := ErrorFromCode(4009999); ok {
+		t.Errorf("ErrorFromCode(4009999) found an undocumented code")
+	}
+	if info, _ := LookupErrorInfo(4000025); info.DocURL != "https://developer.apple.com/documentation/appstoreserverapi/invalidexcluderevokederror" {
+		t.Errorf("LookupErrorInfo(4000025).DocURL = %s", info.DocURL)
+	}
+
+	e := newResponseError(EndpointGetRefundHistory, http.StatusBadRequest, http.Header{}, []byte(`{"errorCode":4000006}`))
+	if errors.Unwrap(e) != InvalidTransactionIdError || e.ErrorMessage() != InvalidTransactionIdError.ErrorMessage() {
+		t.Errorf("newResponseError() = %v, want it backed by InvalidTransactionIdError", e)
+	}
+}
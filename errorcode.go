@@ -0,0 +1,150 @@
+package appstore
+
+import "strings"
+
+// ErrorCategory groups App Store Server API error codes by how a caller should react to them.
+type ErrorCategory int
+
+const (
+	ErrorCategoryClient    ErrorCategory = iota // The request is invalid and must be fixed before sending it again.
+	ErrorCategoryNotFound                       // The account, app, transaction or resource doesn't exist.
+	ErrorCategoryRetryable                      // Apple asks to send the same request again.
+	ErrorCategoryRateLimit                      // The request exceeded the rate limit, see Error.RetryAfter.
+	ErrorCategoryServer                         // Apple failed to process the request.
+)
+
+func (c ErrorCategory) String() string {
+	switch c {
+	case ErrorCategoryClient:
+		return "client"
+	case ErrorCategoryNotFound:
+		return "not-found"
+	case ErrorCategoryRetryable:
+		return "retryable"
+	case ErrorCategoryRateLimit:
+		return "rate-limit"
+	case ErrorCategoryServer:
+		return "server"
+	default:
+		return "unknown"
+	}
+}
+
+const errorDocBaseURL = "https://developer.apple.com/documentation/appstoreserverapi/"
+
+// ErrorInfo describes a documented App Store Server API error code.
+type ErrorInfo struct {
+	Err      *Error        // Sentinel error for the code.
+	Name     string        // Name of the error in Apple's documentation.
+	Category ErrorCategory // How to react to the error.
+	DocURL   string        // Documentation page of the error.
+}
+
+// errorRegistry lists every documented error code by its sentinel.
+// Doc: https://developer.apple.com/documentation/appstoreserverapi/error_codes
+var errorRegistry = func() map[int]ErrorInfo {
+	entries := []struct {
+		err  *Error
+		name string
+	}{
+		{GeneralBadRequestError, "GeneralBadRequestError"},
+		{InvalidAppIdentifierError, "InvalidAppIdentifierError"},
+		{InvalidRequestRevisionError, "InvalidRequestRevisionError"},
+		{InvalidTransactionIdError, "InvalidTransactionIdError"},
+		{InvalidOriginalTransactionIdError, "InvalidOriginalTransactionIdError"},
+		{InvalidExtendByDaysError, "InvalidExtendByDaysError"},
+		{InvalidExtendReasonCodeError, "InvalidExtendReasonCodeError"},
+		{InvalidRequestIdentifierError, "InvalidRequestIdentifierError"},
+		{StartDateTooFarInPastError, "StartDateTooFarInPastError"},
+		{StartDateAfterEndDateError, "StartDateAfterEndDateError"},
+		{InvalidPaginationTokenError, "InvalidPaginationTokenError"},
+		{InvalidStartDateError, "InvalidStartDateError"},
+		{InvalidEndDateError, "InvalidEndDateError"},
+		{PaginationTokenExpiredError, "PaginationTokenExpiredError"},
+		{InvalidNotificationTypeError, "InvalidNotificationTypeError"},
+		{MultipleFiltersSuppliedError, "MultipleFiltersSuppliedError"},
+		{InvalidTestNotificationTokenError, "InvalidTestNotificationTokenError"},
+		{InvalidSortError, "InvalidSortError"},
+		{InvalidProductTypeError, "InvalidProductTypeError"},
+		{InvalidProductIdError, "InvalidProductIdError"},
+		{InvalidSubscriptionGroupIdentifierError, "InvalidSubscriptionGroupIdentifierError"},
+		{InvalidExcludeRevokedError, "InvalidExcludeRevokedError"},
+		{InvalidInAppOwnershipTypeError, "InvalidInAppOwnershipTypeError"},
+		{InvalidEmptyStorefrontCountryCodeListError, "InvalidEmptyStorefrontCountryCodeListError"},
+		{InvalidStorefrontCountryCodeError, "InvalidStorefrontCountryCodeError"},
+		{InvalidRevokedError, "InvalidRevokedError"},
+		{InvalidStatusError, "InvalidStatusError"},
+		{InvalidAccountTenureError, "InvalidAccountTenureError"},
+		{InvalidAppAccountTokenError, "InvalidAppAccountTokenError"},
+		{InvalidConsumptionStatusError, "InvalidConsumptionStatusError"},
+		{InvalidCustomerConsentedError, "InvalidCustomerConsentedError"},
+		{InvalidDeliveryStatusError, "InvalidDeliveryStatusError"},
+		{InvalidLifetimeDollarsPurchasedError, "InvalidLifetimeDollarsPurchasedError"},
+		{InvalidLifetimeDollarsRefundedError, "InvalidLifetimeDollarsRefundedError"},
+		{InvalidPlatformError, "InvalidPlatformError"},
+		{InvalidPlayTimeError, "InvalidPlayTimeError"},
+		{InvalidSampleContentProvidedError, "InvalidSampleContentProvidedError"},
+		{InvalidUserStatusError, "InvalidUserStatusError"},
+		{InvalidTransactionNotConsumableError, "InvalidTransactionNotConsumableError"},
+		{InvalidRefundPreferenceError, "InvalidRefundPreferenceError"},
+		{InvalidTransactionTypeNotSupportedError, "InvalidTransactionTypeNotSupportedError"},
+		{AppTransactionIdNotSupportedError, "AppTransactionIdNotSupportedError"},
+		{InvalidAppAccountTokenUUIDError, "InvalidAppAccountTokenUUIDError"},
+		{FamilyTransactionNotSupportedError, "FamilyTransactionNotSupportedError"},
+		{TransactionIdIsNotOriginalTransactionIdError, "TransactionIdIsNotOriginalTransactionIdError"},
+		{SubscriptionExtensionIneligibleError, "SubscriptionExtensionIneligibleError"},
+		{SubscriptionMaxExtensionError, "SubscriptionMaxExtensionError"},
+		{FamilySharedSubscriptionExtensionIneligibleError, "FamilySharedSubscriptionExtensionIneligibleError"},
+		{AccountNotFoundError, "AccountNotFoundError"},
+		{AccountNotFoundRetryableError, "AccountNotFoundRetryableError"},
+		{AppNotFoundError, "AppNotFoundError"},
+		{AppNotFoundRetryableError, "AppNotFoundRetryableError"},
+		{OriginalTransactionIdNotFoundError, "OriginalTransactionIdNotFoundError"},
+		{OriginalTransactionIdNotFoundRetryableError, "OriginalTransactionIdNotFoundRetryableError"},
+		{ServerNotificationURLNotFoundError, "ServerNotificationURLNotFoundError"},
+		{TestNotificationNotFoundError, "TestNotificationNotFoundError"},
+		{StatusRequestNotFoundError, "StatusRequestNotFoundError"},
+		{TransactionIdNotFoundError, "TransactionIdNotFoundError"},
+		{RateLimitExceededError, "RateLimitExceededError"},
+		{GeneralInternalError, "GeneralInternalError"},
+		{GeneralInternalRetryableError, "GeneralInternalRetryableError"},
+	}
+	registry := make(map[int]ErrorInfo, len(entries))
+	for _, e := range entries {
+		registry[e.err.errorCode] = ErrorInfo{
+			Err:      e.err,
+			Name:     e.name,
+			Category: categoryOf(e.err.errorCode),
+			DocURL:   errorDocBaseURL + strings.ToLower(e.name),
+		}
+	}
+	return registry
+}()
+
+// categoryOf derives the category from the code, so codes Apple documents later are still classified.
+func categoryOf(code int) ErrorCategory {
+	switch {
+	case retryableErrorCodes[code] && code != RateLimitExceededError.errorCode:
+		return ErrorCategoryRetryable
+	case code == RateLimitExceededError.errorCode:
+		return ErrorCategoryRateLimit
+	case code/10000 == 404:
+		return ErrorCategoryNotFound
+	case code/1000000 == 5:
+		return ErrorCategoryServer
+	default:
+		return ErrorCategoryClient
+	}
+}
+
+// ErrorFromCode returns the sentinel error of a documented errorCode.
+func ErrorFromCode(code int) (*Error, bool) {
+	info, ok := errorRegistry[code]
+	return info.Err, ok
+}
+
+// LookupErrorInfo returns the registry entry of a documented errorCode.
+func LookupErrorInfo(code int) (ErrorInfo, bool) {
+	info, ok := errorRegistry[code]
+	return info, ok
+}
@@ -0,0 +1,162 @@
+package appstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type FaultKind int
+
+const (
+	FaultLatency         FaultKind = iota // Delays the request by Latency, then sends it.
+	FaultRateLimit                        // Answers 429 with RateLimitExceededError and a Retry-After of RetryAfter from now.
+	FaultServerError                      // Answers StatusCode with ErrorCode in the body.
+	FaultTruncatedBody                    // Sends the request and cuts the response body short with io.ErrUnexpectedEOF.
+	FaultConnectionReset                  // Fails the request with a connection reset.
+)
+
+// Fault is injected into a matching request with the given probability.
+type Fault struct {
+	Kind        FaultKind
+	Probability float64       // Between 0 and 1.
+	Latency     time.Duration // FaultLatency only.
+	RetryAfter  time.Duration // FaultRateLimit only. Default is one second.
+	StatusCode  int           // FaultServerError only. Default is 500.
+	ErrorCode   int           // FaultServerError only. Default is GeneralInternalRetryableError.
+}
+
+// FaultRule applies its faults to the requests it matches. Empty fields match every request.
+type FaultRule struct {
+	Path     string   // path.Match pattern for the request path, such as "/inApps/v1/transactions/*".
+	Endpoint Endpoint // Endpoint of the request, see EndpointFromRequest.
+	Faults   []Fault  // Rolled in order; latency adds up, and the first other fault that hits ends the request.
+}
+
+type FaultConfig struct {
+	Seed  int64       // Seeds the probability rolls, so a sequential test sees the same faults on every run.
+	Rules []FaultRule // Every matching rule applies, in order.
+}
+
+// InjectFaults wraps c with the faults of config to simulate App Store misbehavior in resilience tests.
+func InjectFaults(c HTTPClient, config FaultConfig) DoFunc {
+	var mu sync.Mutex
+	rnd := rand.New(rand.NewSource(config.Seed))
+	roll := func(p float64) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return rnd.Float64() < p
+	}
+
+	return func(req *http.Request) (*http.Response, error) {
+		endpoint := EndpointFromRequest(req)
+		truncate := false
+		for _, rule := range config.Rules {
+			if !rule.matches(req, endpoint) {
+				continue
+			}
+			for _, f := range rule.Faults {
+				if !roll(f.Probability) {
+					continue
+				}
+				switch f.Kind {
+				case FaultLatency:
+					timer := time.NewTimer(f.Latency)
+					select {
+					case <-timer.C:
+					case <-req.Context().Done():
+						timer.Stop()
+						return nil, req.Context().Err()
+					}
+				case FaultRateLimit:
+					return f.rateLimitResponse(req), nil
+				case FaultServerError:
+					return f.serverErrorResponse(req), nil
+				case FaultTruncatedBody:
+					truncate = true
+				case FaultConnectionReset:
+					return nil, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+				}
+			}
+		}
+
+		resp, err := c.Do(req)
+		if err != nil || !truncate {
+			return resp, err
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(io.MultiReader(bytes.NewReader(b[:len(b)/2]), errReader{io.ErrUnexpectedEOF}))
+		return resp, nil
+	}
+}
+
+func (r FaultRule) matches(req *http.Request, endpoint Endpoint) bool {
+	if r.Endpoint != "" && r.Endpoint != endpoint {
+		return false
+	}
+	if r.Path != "" {
+		if ok, _ := path.Match(r.Path, req.URL.Path); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (f Fault) rateLimitResponse(req *http.Request) *http.Response {
+	retryAfter := f.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+	resp := faultResponse(req, http.StatusTooManyRequests, RateLimitExceededError)
+	resp.Header.Set("Retry-After", strconv.FormatInt(time.Now().Add(retryAfter).UnixMilli(), 10))
+	return resp
+}
+
+func (f Fault) serverErrorResponse(req *http.Request) *http.Response {
+	statusCode := f.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+	apiErr := GeneralInternalRetryableError
+	if f.ErrorCode != 0 {
+		apiErr = newError(f.ErrorCode, "Injected fault.")
+	}
+	return faultResponse(req, statusCode, apiErr)
+}
+
+func faultResponse(req *http.Request, statusCode int, apiErr *Error) *http.Response {
+	body := fmt.Sprintf(`{"errorCode":%d,"errorMessage":%q}`, apiErr.errorCode, apiErr.errorMessage)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,59 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+)
+
+func TestInjectFaults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"signedTransactionInfo":"jws"}`))
+	}))
+	defer srv.Close()
+
+	newClient := func(faults ...Fault) *StoreClient {
+		hc := InjectFaults(srv.Client(), FaultConfig{Seed: 1, Rules: []FaultRule{{Path: "/inApps/v1/transactions/*", Faults: faults}}})
+		return NewStoreClientWithHTTPClient(newTestStoreConfig(t, srv.URL), hc)
+	}
+
+	var apiErr *Error
+	_, err := newClient(Fault{Kind: FaultRateLimit, Probability: 1}).GetTransactionInfo(context.TODO(), "1000")
+	if !errors.As(err, &apiErr) || !errors.Is(err, RateLimitExceededError) || apiErr.RetryAfter() == 0 {
+		t.Errorf("GetTransactionInfo() error = %v, want a rate limit error with Retry-After", err)
+	}
+	_, err = newClient(Fault{Kind: FaultServerError, Probability: 1, ErrorCode: 5000000}).GetTransactionInfo(context.TODO(), "1000")
+	if !errors.Is(err, GeneralInternalError) {
+		t.Errorf("GetTransactionInfo() error = %v, want GeneralInternalError", err)
+	}
+	_, err = newClient(Fault{Kind: FaultTruncatedBody, Probability: 1}).GetTransactionInfo(context.TODO(), "1000")
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !ShouldRetryDefault(http.StatusOK, err) {
+		t.Errorf("GetTransactionInfo() error = %v, want io.ErrUnexpectedEOF", err)
+	}
+	_, err = newClient(Fault{Kind: FaultConnectionReset, Probability: 1}).GetTransactionInfo(context.TODO(), "1000")
+	if !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("GetTransactionInfo() error = %v, want a connection reset", err)
+	}
+	if _, err = newClient(Fault{Kind: FaultConnectionReset, Probability: 1}).GetALLSubscriptionStatuses(context.TODO(), "1000"); err != nil {
+		t.Errorf("GetALLSubscriptionStatuses() error = %v, want paths outside the rule untouched", err)
+	}
+
+	outcomes := func() (s string) {
+		a := newClient(Fault{Kind: FaultServerError, Probability: 0.5})
+		for i := 0; i < 16; i++ {
+			if _, err := a.GetTransactionInfo(context.TODO(), "1000"); err != nil {
+				s += "x"
+			} else {
+				s += "."
+			}
+		}
+		return s
+	}
+	if first, second := outcomes(), outcomes(); first != second {
+		t.Errorf("outcomes %q and %q differ for the same seed", first, second)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"io"
 	"net/http"
 	"net/textproto"
-	"strconv"
 	"time"
 )
 
@@ -72,12 +71,49 @@ func RequireResponseStatus(c HTTPClient, status ...int) DoFunc {
 			return resp, err
 		}
 		if !valid[resp.StatusCode] {
-			return resp, fmt.Errorf("received invalid status code: %d", resp.StatusCode)
+			return resp, newResponseError(EndpointFromRequest(req), resp.StatusCode, resp.Header, nil)
 		}
 		return resp, nil
 	}
 }
 
+// sensitiveHeaders are never logged or recorded in clear.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// RedactHeader returns a copy of h with the credentials headers, and any extra names, replaced by REDACTED.
+func RedactHeader(h http.Header, names ...string) http.Header {
+	out := h.Clone()
+	if out == nil {
+		out = make(http.Header)
+	}
+	for _, k := range append(sensitiveHeaders, names...) {
+		if _, ok := out[textproto.CanonicalMIMEHeaderKey(k)]; ok {
+			out.Set(k, redactedValue)
+		}
+	}
+	return out
+}
+
+// LogRequests writes a line per request to logf, such as log.Printf, with the endpoint, status, duration
+// and request headers. The Authorization header and its bearer token are redacted.
+func LogRequests(c HTTPClient, logf func(format string, v ...any)) DoFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := c.Do(req)
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		header := RedactHeader(req.Header)
+		if err != nil {
+			logf("appstore %s %s %s status=%d duration=%s header=%v err=%v", EndpointFromRequest(req), req.Method, req.URL.Path, status, time.Since(start), header, err)
+		} else {
+			logf("appstore %s %s %s status=%d duration=%s header=%v", EndpointFromRequest(req), req.Method, req.URL.Path, status, time.Since(start), header)
+		}
+		return resp, err
+	}
+}
+
 type Initializer func(HTTPClient) (DoFunc, error)
 
 func SetInitializer(c HTTPClient, init Initializer) DoFunc {
@@ -142,7 +178,7 @@ func SetResponseErrorHandler(c HTTPClient, u Unmarshaller, ptr any) DoFunc {
 			return resp, err
 		}
 
-		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusUnauthorized {
+		if resp.StatusCode == http.StatusOK {
 			return resp, nil
 		}
 
@@ -151,19 +187,20 @@ func SetResponseErrorHandler(c HTTPClient, u Unmarshaller, ptr any) DoFunc {
 			return resp, err
 		}
 
-		var rErr appStoreAPIErrorResp
-		if err = u(b, &rErr); err != nil {
-			return resp, err
+		if resp.StatusCode == http.StatusUnauthorized {
+			respErr := newResponseError(EndpointFromRequest(req), resp.StatusCode, resp.Header, b)
+			return resp, newAuthenticationError(respErr, bearerFromRequest(req), "", false)
 		}
 
-		if rErr.ErrorCode == 4290000 {
-			retryAfter, err := strconv.ParseInt(req.Header.Get("Retry-After"), 10, 64)
-			if err == nil {
-				return resp, &Error{errorCode: rErr.ErrorCode, errorMessage: rErr.ErrorMessage, retryAfter: retryAfter}
-			}
+		var rErr appStoreAPIErrorResp
+		if err = u(b, &rErr); err != nil || rErr.ErrorCode == 0 {
+			// Not an App Store error body, such as an HTML page from a proxy.
+			return resp, newResponseError(EndpointFromRequest(req), resp.StatusCode, resp.Header, b)
 		}
 
-		return resp, &Error{errorCode: rErr.ErrorCode, errorMessage: rErr.ErrorMessage}
+		e := newErrorFromCode(rErr.ErrorCode, rErr.ErrorMessage, resp.Header)
+		e.httpStatus, e.endpoint, e.header, e.rawBody = resp.StatusCode, EndpointFromRequest(req), resp.Header, b
+		return resp, e
 	}
 }
 
@@ -190,28 +227,13 @@ func SetResponseBodyHandler(c HTTPClient, u Unmarshaller, ptr any) DoFunc {
 	}
 }
 
+// RateLimit allows reqPerMin requests per minute across every endpoint.
+// Use SetRateLimiter to apply Apple's per-endpoint quotas instead.
 func RateLimit(c HTTPClient, reqPerMin int) DoFunc {
-	ticker := time.NewTicker(time.Second * time.Duration(60))
-	ch := make(chan struct{}, reqPerMin)
-	go func() {
-		for range ticker.C {
-			for i := 0; i < reqPerMin; i++ {
-				select {
-				case ch <- struct{}{}:
-				default:
-					break
-				}
-			}
-		}
-	}()
-	return func(req *http.Request) (*http.Response, error) {
-		select {
-		case <-ch:
-		case <-req.Context().Done():
-			return nil, req.Context().Err()
-		}
-		return c.Do(req)
-	}
+	l := NewRateLimiter(RateLimiterConfig{})
+	l.shared = true
+	l.limits[EndpointUnknown] = Rate{Requests: reqPerMin, Per: time.Minute}
+	return SetRateLimiter(c, l)
 }
 
 func ShouldRetryDefault(status int, err error) bool {
@@ -237,6 +259,7 @@ func SetRetry(c HTTPClient, bo Backoff, shouldRetry func(int, error) bool) DoFun
 		var resp *http.Response
 		var err error
 		var pause time.Duration
+		bo := newRequestBackoff(bo)
 
 		for {
 			select {
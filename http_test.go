@@ -0,0 +1,54 @@
+package appstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"signedTransactionInfo":"jws"}`))
+	}))
+	defer srv.Close()
+
+	var lines []string
+	logf := func(format string, v ...any) { lines = append(lines, fmt.Sprintf(format, v...)) }
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+PathTransactionInfo, nil)
+	req.Header.Set("Authorization", "Bearer secret.bearer.token")
+	req.Header.Set("Accept", "application/json")
+	if _, err := LogRequests(http.DefaultClient, logf).Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || strings.Contains(lines[0], "secret.bearer.token") || !strings.Contains(lines[0], "Authorization:[REDACTED]") ||
+		!strings.Contains(lines[0], "GetTransactionInfo") || !strings.Contains(lines[0], "status=200") {
+		t.Errorf("logged %q", lines)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret.bearer.token" {
+		t.Errorf("LogRequests changed the request headers")
+	}
+}
+
+func TestSetResponseErrorHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>502 Bad Gateway</html>"))
+	}))
+	defer srv.Close()
+
+	c := SetResponseErrorHandler(http.DefaultClient, json.Unmarshal, nil)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/inApps/v1/transactions/1000", nil)
+	_, err := c.Do(req)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Do() error = %v, want an *Error", err)
+	}
+	if apiErr.HTTPStatus() != http.StatusBadGateway || !apiErr.IsRetryable() || string(apiErr.RawBody()) != "<html>502 Bad Gateway</html>" || apiErr.Header().Get("Content-Type") != "text/html" {
+		t.Errorf("Do() error = %v, status = %d, body = %q", err, apiErr.HTTPStatus(), apiErr.RawBody())
+	}
+}
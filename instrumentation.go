@@ -0,0 +1,105 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// RequestInfo describes one attempt of an App Store Server API call.
+type RequestInfo struct {
+	Endpoint      Endpoint      // Operation name, free of the transaction identifiers in the URL.
+	Method        string        // HTTP method.
+	Attempt       int           // 1 for the first attempt, incremented by every retry.
+	RateLimitWait time.Duration // Time spent waiting for the client's RateLimiter before the attempt.
+	StatusCode    int           // HTTP status code, 0 when no response was received.
+	ErrorCode     int           // Apple errorCode of an error response, 0 otherwise.
+	Duration      time.Duration // Time spent sending the request and reading the response.
+	Err           error         // Error the attempt ended with, nil on success.
+}
+
+// Instrumentation is invoked around every attempt StoreClient makes, retries included.
+type Instrumentation interface {
+	// BeforeRequest is called once the attempt got past the rate limiter. The returned context is used
+	// for the request, so implementations can start a trace span in it.
+	BeforeRequest(ctx context.Context, info *RequestInfo) context.Context
+	// AfterRequest is called with the outcome of the attempt filled in.
+	AfterRequest(ctx context.Context, info *RequestInfo)
+}
+
+type multiInstrumentation []Instrumentation
+
+// MultiInstrumentation invokes every instrumentation in order.
+func MultiInstrumentation(instrumentations ...Instrumentation) Instrumentation {
+	return multiInstrumentation(instrumentations)
+}
+
+func (m multiInstrumentation) BeforeRequest(ctx context.Context, info *RequestInfo) context.Context {
+	for _, in := range m {
+		ctx = in.BeforeRequest(ctx, info)
+	}
+	return ctx
+}
+
+func (m multiInstrumentation) AfterRequest(ctx context.Context, info *RequestInfo) {
+	for _, in := range m {
+		in.AfterRequest(ctx, info)
+	}
+}
+
+func apiErrorCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.errorCode
+	}
+	return 0
+}
+
+// MetricsRegistry is the small slice of a metrics library that MetricsInstrumentation needs.
+// Adapt it to Prometheus, OpenTelemetry or StatsD without this package importing them.
+type MetricsRegistry interface {
+	// AddCounter adds value to the counter with the given name and labels.
+	AddCounter(name string, value float64, labels map[string]string)
+	// ObserveHistogram records value in the histogram with the given name and labels.
+	ObserveHistogram(name string, value float64, labels map[string]string)
+}
+
+// Metric names reported by MetricsInstrumentation.
+const (
+	MetricRequestsTotal          = "appstore_requests_total"
+	MetricRequestDurationSeconds = "appstore_request_duration_seconds"
+	MetricRetriesTotal           = "appstore_retries_total"
+	MetricRateLimitWaitSeconds   = "appstore_rate_limit_wait_seconds"
+)
+
+type metricsInstrumentation struct {
+	registry MetricsRegistry
+}
+
+// NewMetricsInstrumentation reports request counts, latencies, retries and rate-limit waits to registry.
+// Requests are labeled with endpoint, method, status and Apple error_code.
+func NewMetricsInstrumentation(registry MetricsRegistry) Instrumentation {
+	return &metricsInstrumentation{registry: registry}
+}
+
+func (m *metricsInstrumentation) BeforeRequest(ctx context.Context, info *RequestInfo) context.Context {
+	endpoint := map[string]string{"endpoint": string(info.Endpoint)}
+	if info.Attempt > 1 {
+		m.registry.AddCounter(MetricRetriesTotal, 1, endpoint)
+	}
+	m.registry.ObserveHistogram(MetricRateLimitWaitSeconds, info.RateLimitWait.Seconds(), endpoint)
+	return ctx
+}
+
+func (m *metricsInstrumentation) AfterRequest(_ context.Context, info *RequestInfo) {
+	m.registry.AddCounter(MetricRequestsTotal, 1, map[string]string{
+		"endpoint":   string(info.Endpoint),
+		"method":     info.Method,
+		"status":     strconv.Itoa(info.StatusCode),
+		"error_code": strconv.Itoa(info.ErrorCode),
+	})
+	m.registry.ObserveHistogram(MetricRequestDurationSeconds, info.Duration.Seconds(), map[string]string{
+		"endpoint": string(info.Endpoint),
+	})
+}
@@ -0,0 +1,41 @@
+//go:build go1.21
+
+package appstore
+
+import (
+	"context"
+	"log/slog"
+)
+
+type slogInstrumentation struct {
+	logger *slog.Logger
+}
+
+// NewSlogInstrumentation logs every attempt to logger, at debug level when it succeeded and at warn level otherwise.
+func NewSlogInstrumentation(logger *slog.Logger) Instrumentation {
+	return &slogInstrumentation{logger: logger}
+}
+
+func (s *slogInstrumentation) BeforeRequest(ctx context.Context, _ *RequestInfo) context.Context {
+	return ctx
+}
+
+func (s *slogInstrumentation) AfterRequest(ctx context.Context, info *RequestInfo) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("endpoint", string(info.Endpoint)),
+		slog.String("method", info.Method),
+		slog.Int("attempt", info.Attempt),
+		slog.Int("status", info.StatusCode),
+		slog.Duration("duration", info.Duration),
+		slog.Duration("rate_limit_wait", info.RateLimitWait),
+	}
+	if info.ErrorCode != 0 {
+		attrs = append(attrs, slog.Int("error_code", info.ErrorCode))
+	}
+	if info.Err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", info.Err.Error()))
+	}
+	s.logger.LogAttrs(ctx, level, "appstore request", attrs...)
+}
@@ -0,0 +1,86 @@
+package appstore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeRegistry struct {
+	mu         sync.Mutex
+	counters   map[string]float64
+	histograms map[string]int
+}
+
+func (r *fakeRegistry) AddCounter(name string, value float64, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[name+"/"+labels["endpoint"]+"/"+labels["status"]+"/"+labels["error_code"]] += value
+}
+
+func (r *fakeRegistry) ObserveHistogram(name string, _ float64, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.histograms[name+"/"+labels["endpoint"]]++
+}
+
+func TestStoreClient_Instrumentation(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errorCode":5000001,"errorMessage":"An unknown error occurred. Please try again."}`))
+			return
+		}
+		w.Write([]byte(`{"signedTransactionInfo":"jws"}`))
+	}))
+	defer srv.Close()
+
+	registry := &fakeRegistry{counters: map[string]float64{}, histograms: map[string]int{}}
+	var infos []RequestInfo
+	c := newTestStoreConfig(t, srv.URL)
+	c.RetryPolicy = &RetryPolicy{Backoff: &ExponentialBackoff{Initial: time.Millisecond}}
+	c.Instrumentation = MultiInstrumentation(NewMetricsInstrumentation(registry), recordingInstrumentation(func(info RequestInfo) {
+		infos = append(infos, info)
+	}))
+
+	if _, err := NewStoreClient(c).GetTransactionInfo(context.TODO(), "2000000000000001"); err != nil {
+		t.Fatalf("GetTransactionInfo() error = %v", err)
+	}
+
+	if len(infos) != 2 || infos[0].ErrorCode != 5000001 || infos[0].Err == nil || infos[1].Attempt != 2 || infos[1].StatusCode != http.StatusOK {
+		t.Errorf("AfterRequest() infos = %+v", infos)
+	}
+	for _, info := range infos {
+		if info.Endpoint != EndpointGetTransactionInfo || info.Method != http.MethodGet || info.Duration <= 0 {
+			t.Errorf("AfterRequest() info = %+v", info)
+		}
+	}
+
+	wantCounters := map[string]float64{
+		MetricRequestsTotal + "/GetTransactionInfo/500/5000001": 1,
+		MetricRequestsTotal + "/GetTransactionInfo/200/0":       1,
+		MetricRetriesTotal + "/GetTransactionInfo//":            1,
+	}
+	for k, v := range wantCounters {
+		if registry.counters[k] != v {
+			t.Errorf("counter %s = %v, want %v (all: %v)", k, registry.counters[k], v, registry.counters)
+		}
+	}
+	if registry.histograms[MetricRequestDurationSeconds+"/GetTransactionInfo"] != 2 {
+		t.Errorf("histograms = %v", registry.histograms)
+	}
+}
+
+type recordingInstrumentation func(RequestInfo)
+
+func (r recordingInstrumentation) BeforeRequest(ctx context.Context, _ *RequestInfo) context.Context {
+	return ctx
+}
+
+func (r recordingInstrumentation) AfterRequest(_ context.Context, info *RequestInfo) {
+	r(*info)
+}
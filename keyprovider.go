@@ -0,0 +1,145 @@
+package appstore
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrNoSigningKey = errors.New("token: key provider has no signing key")
+
+// SigningKey is an App Store Connect In-App Purchase key.
+type SigningKey struct {
+	KeyID  string // Key ID from App Store Connect (Ex: 2X9R4HXF34)
+	Signer Signer // Signs with the key, see NewKeySigner and NewCryptoSigner.
+	Weight int    // Share of tokens signed with the key by WeightedKeyProvider. Default is 1.
+}
+
+// NewSigningKey loads the .p8 key content of keyID.
+func NewSigningKey(keyID string, keyContent []byte) (SigningKey, error) {
+	signer, err := NewKeySigner(keyContent)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	return SigningKey{KeyID: keyID, Signer: signer}, nil
+}
+
+// KeyProvider hands Token the key to sign each new token with, so keys can be rotated
+// without rebuilding the StoreClient.
+type KeyProvider interface {
+	// CurrentKey returns the key for the next token.
+	CurrentKey() (SigningKey, error)
+	// KeyRejected reports that the App Store answered 401 Unauthorized to a token signed with keyID.
+	KeyRejected(keyID string)
+}
+
+// FailoverKeyProvider signs with the first key, the primary, and moves on to the next key in order
+// once a key is rejected, wrapping around after the last one.
+type FailoverKeyProvider struct {
+	mu      sync.Mutex
+	keys    []SigningKey
+	current int
+}
+
+func NewFailoverKeyProvider(keys ...SigningKey) *FailoverKeyProvider {
+	return &FailoverKeyProvider{keys: keys}
+}
+
+// SetKeys replaces the keys and goes back to the primary.
+func (p *FailoverKeyProvider) SetKeys(keys ...SigningKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+	p.current = 0
+}
+
+func (p *FailoverKeyProvider) CurrentKey() (SigningKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.keys) == 0 {
+		return SigningKey{}, ErrNoSigningKey
+	}
+	return p.keys[p.current], nil
+}
+
+func (p *FailoverKeyProvider) KeyRejected(keyID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.keys) > 0 && p.keys[p.current].KeyID == keyID {
+		p.current = (p.current + 1) % len(p.keys)
+	}
+}
+
+// WeightedKeyProvider spreads tokens over its keys in proportion to their Weight.
+// Rejected keys are left out until SetKeys is called, or until every key has been rejected.
+type WeightedKeyProvider struct {
+	mu       sync.Mutex
+	keys     []SigningKey
+	rejected map[string]bool
+	rand     Rand
+}
+
+// NewWeightedKeyProvider picks keys with the math/rand global source, see SetRand.
+func NewWeightedKeyProvider(keys ...SigningKey) *WeightedKeyProvider {
+	return &WeightedKeyProvider{keys: keys, rejected: make(map[string]bool), rand: globalRand{}}
+}
+
+// SetRand replaces the random source of the picks, such as with a seeded one in tests.
+func (p *WeightedKeyProvider) SetRand(r Rand) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rand = randOrGlobal(r)
+}
+
+// SetKeys replaces the keys and forgets the rejected ones.
+func (p *WeightedKeyProvider) SetKeys(keys ...SigningKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+	p.rejected = make(map[string]bool)
+}
+
+func (p *WeightedKeyProvider) CurrentKey() (SigningKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.keys) == 0 {
+		return SigningKey{}, ErrNoSigningKey
+	}
+
+	var total int64
+	for _, k := range p.keys {
+		if !p.rejected[k.KeyID] {
+			total += int64(keyWeight(k))
+		}
+	}
+	if total == 0 {
+		// Every key was rejected, so give them all another chance rather than failing for good.
+		p.rejected = make(map[string]bool)
+		for _, k := range p.keys {
+			total += int64(keyWeight(k))
+		}
+	}
+
+	n := p.rand.Int63n(total)
+	for _, k := range p.keys {
+		if p.rejected[k.KeyID] {
+			continue
+		}
+		if n -= int64(keyWeight(k)); n < 0 {
+			return k, nil
+		}
+	}
+	return p.keys[len(p.keys)-1], nil
+}
+
+func (p *WeightedKeyProvider) KeyRejected(keyID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rejected[keyID] = true
+}
+
+func keyWeight(k SigningKey) int {
+	if k.Weight <= 0 {
+		return 1
+	}
+	return k.Weight
+}
@@ -0,0 +1,81 @@
+package appstore
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStoreClient_KeyProvider(t *testing.T) {
+	var kids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kid := strings.TrimRight(decodeTokenClaims(bearerFromRequest(r)).KeyID, "*")
+		kids = append(kids, kid)
+		if kid == "OLD1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"signedTransactionInfo":"jws"}`))
+	}))
+	defer srv.Close()
+
+	oldKey, err := NewSigningKey("OLD1", newTestKeyContent(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := NewSigningKey("NEW1", newTestKeyContent(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var used []string
+	c := newTestStoreConfig(t, srv.URL)
+	c.KeyProvider = NewFailoverKeyProvider(oldKey, newKey)
+	c.OnKeyUsed = func(keyID string) { used = append(used, keyID) }
+	a := NewStoreClient(c)
+
+	for i := 0; i < 2; i++ {
+		if _, err = a.GetTransactionInfo(context.TODO(), "1000"); err != nil {
+			t.Fatalf("GetTransactionInfo() error = %v", err)
+		}
+	}
+	if strings.Join(kids, ",") != "OLD1,NEW1,NEW1" || strings.Join(used, ",") != "OLD1,NEW1" {
+		t.Errorf("requests signed with %v, keys used %v, want a switch to NEW1 after the 401", kids, used)
+	}
+	if a.Token.KeyID != "SKEYID" || a.Token.CurrentKeyID() != "NEW1" {
+		t.Errorf("KeyID = %s, CurrentKeyID() = %s, want the configured SKEYID and the provider's NEW1", a.Token.KeyID, a.Token.CurrentKeyID())
+	}
+}
+
+func TestWeightedKeyProvider(t *testing.T) {
+	p := NewWeightedKeyProvider(SigningKey{KeyID: "A", Weight: 3}, SigningKey{KeyID: "B"})
+	p.SetRand(rand.New(rand.NewSource(1)))
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		k, _ := p.CurrentKey()
+		counts[k.KeyID]++
+	}
+	if counts["A"] < 650 || counts["A"] > 850 {
+		t.Errorf("picked %v, want about three A for every B", counts)
+	}
+
+	p.KeyRejected("A")
+	for i := 0; i < 10; i++ {
+		if k, _ := p.CurrentKey(); k.KeyID != "B" {
+			t.Fatalf("CurrentKey() = %s after A was rejected", k.KeyID)
+		}
+	}
+	p.KeyRejected("B")
+	if _, err := p.CurrentKey(); err != nil {
+		t.Errorf("CurrentKey() error = %v once every key was rejected", err)
+	}
+
+	p.SetKeys()
+	if _, err := p.CurrentKey(); err != ErrNoSigningKey {
+		t.Errorf("CurrentKey() error = %v, want ErrNoSigningKey", err)
+	}
+}
@@ -14,8 +14,10 @@ type Environment string
 
 // Environment https://developer.apple.com/documentation/appstoreserverapi/environment
 const (
-	Sandbox    Environment = "Sandbox"
-	Production Environment = "Production"
+	Sandbox      Environment = "Sandbox"
+	Production   Environment = "Production"
+	LocalTesting Environment = "LocalTesting"
+	Xcode        Environment = "Xcode"
 )
 
 // HistoryResponse https://developer.apple.com/documentation/appstoreserverapi/historyresponse
@@ -292,6 +294,10 @@ const (
 	NotificationTypeV2ExternalPurchaseToken  NotificationTypeV2 = "EXTERNAL_PURCHASE_TOKEN"
 	NotificationTypeV2RenewalExtension       NotificationTypeV2 = "RENEWAL_EXTENSION"
 	NotificationTypeV2Test                   NotificationTypeV2 = "TEST"
+	NotificationTypeV2MetadataUpdate         NotificationTypeV2 = "METADATA_UPDATE" // Advanced Commerce API
+	NotificationTypeV2Migration              NotificationTypeV2 = "MIGRATION"       // Advanced Commerce API
+	NotificationTypeV2PriceChange            NotificationTypeV2 = "PRICE_CHANGE"    // Advanced Commerce API
+	NotificationTypeV2RescindConsent         NotificationTypeV2 = "RESCIND_CONSENT"
 )
 
 // SubtypeV2 is type
@@ -313,6 +319,14 @@ const (
 	SubTypeV2BillingRecovery   SubtypeV2 = "BILLING_RECOVERY"
 	SubTypeV2Pending           SubtypeV2 = "PENDING"
 	SubTypeV2Accepted          SubtypeV2 = "ACCEPTED"
+	// PRODUCT_NOT_FOR_SALE goes with EXPIRED, SUMMARY and FAILURE with RENEWAL_EXTENSION,
+	// UNREPORTED, ACTIVE_TOKEN_REMINDER and CREATED with EXTERNAL_PURCHASE_TOKEN.
+	SubTypeV2ProductNotForSale   SubtypeV2 = "PRODUCT_NOT_FOR_SALE"
+	SubTypeV2Summary             SubtypeV2 = "SUMMARY"
+	SubTypeV2Failure             SubtypeV2 = "FAILURE"
+	SubTypeV2Unreported          SubtypeV2 = "UNREPORTED"
+	SubTypeV2ActiveTokenReminder SubtypeV2 = "ACTIVE_TOKEN_REMINDER"
+	SubTypeV2Created             SubtypeV2 = "CREATED"
 )
 
 // NotificationHistoryResponses https://developer.apple.com/documentation/appstoreserverapi/notificationhistoryresponse
@@ -361,28 +375,105 @@ type NotificationV2 struct {
 	SignedPayload string `json:"signedPayload"`
 }
 
-// Notification signed payload
+// Notification signed payload https://developer.apple.com/documentation/appstoreservernotifications/responsebodyv2decodedpayload
+// Data, Summary or ExternalPurchaseToken is set depending on NotificationType: Summary for the SUMMARY subtype of
+// RENEWAL_EXTENSION, ExternalPurchaseToken for EXTERNAL_PURCHASE_TOKEN and Data for every other notification.
 type NotificationPayload struct {
 	jwt.RegisteredClaims
-	NotificationType    string           `json:"notificationType"`
-	Subtype             string           `json:"subtype"`
-	NotificationUUID    string           `json:"notificationUUID"`
-	NotificationVersion string           `json:"notificationVersion"`
-	Data                NotificationData `json:"data"`
-}
-
-// Notification Data
+	NotificationType      NotificationTypeV2     `json:"notificationType"`
+	Subtype               SubtypeV2              `json:"subtype"`
+	NotificationUUID      string                 `json:"notificationUUID"`
+	NotificationVersion   string                 `json:"notificationVersion"` // Deprecated: the App Store sends the version in Version.
+	Version               string                 `json:"version"`
+	SignedDate            int64                  `json:"signedDate"`
+	Data                  NotificationData       `json:"data"`
+	Summary               *NotificationSummary   `json:"summary,omitempty"`
+	ExternalPurchaseToken *ExternalPurchaseToken `json:"externalPurchaseToken,omitempty"`
+	AppData               *NotificationAppData   `json:"appData,omitempty"`
+}
+
+// Notification Data https://developer.apple.com/documentation/appstoreservernotifications/data
 type NotificationData struct {
 	jwt.RegisteredClaims
-	AppAppleID               int    `json:"appAppleId"`
-	BundleID                 string `json:"bundleId"`
-	BundleVersion            string `json:"bundleVersion"`
-	ConsumptionRequestReason string `json:"consumptionRequestReason"`
-	Environment              string `json:"environment"`
-	SignedRenewalInfo        string `json:"signedRenewalInfo"`
-	SignedTransactionInfo    string `json:"signedTransactionInfo"`
-	Status                   int    `json:"status"`
-}
+	AppAppleID               int                      `json:"appAppleId"`
+	BundleID                 string                   `json:"bundleId"`
+	BundleVersion            string                   `json:"bundleVersion"`
+	ConsumptionRequestReason ConsumptionRequestReason `json:"consumptionRequestReason,omitempty"`
+	Environment              Environment              `json:"environment"`
+	SignedRenewalInfo        string                   `json:"signedRenewalInfo"`
+	SignedTransactionInfo    string                   `json:"signedTransactionInfo"`
+	Status                   SubscriptionStatus       `json:"status,omitempty"`
+}
+
+// NotificationSummary https://developer.apple.com/documentation/appstoreservernotifications/summary
+// It reports the outcome of a MassExtendRenewalDate request.
+type NotificationSummary struct {
+	RequestIdentifier      string      `json:"requestIdentifier"`
+	Environment            Environment `json:"environment"`
+	AppAppleId             int64       `json:"appAppleId"`
+	BundleId               string      `json:"bundleId"`
+	ProductId              string      `json:"productId"`
+	StorefrontCountryCodes []string    `json:"storefrontCountryCodes"`
+	FailedCount            int64       `json:"failedCount"`
+	SucceededCount         int64       `json:"succeededCount"`
+}
+
+// ExternalPurchaseToken https://developer.apple.com/documentation/appstoreservernotifications/externalpurchasetoken
+type ExternalPurchaseToken struct {
+	ExternalPurchaseId string `json:"externalPurchaseId"`
+	TokenCreationDate  int64  `json:"tokenCreationDate"`
+	AppAppleId         int64  `json:"appAppleId"`
+	BundleId           string `json:"bundleId"`
+}
+
+// NotificationAppData https://developer.apple.com/documentation/appstoreservernotifications/appdata
+type NotificationAppData struct {
+	AppAppleId               int64       `json:"appAppleId"`
+	BundleId                 string      `json:"bundleId"`
+	Environment              Environment `json:"environment"`
+	SignedAppTransactionInfo string      `json:"signedAppTransactionInfo"`
+}
+
+// JWSAppTransaction https://developer.apple.com/documentation/appstoreserverapi/jwsapptransactiondecodedpayload
+type JWSAppTransaction struct {
+	jwt.RegisteredClaims
+	ReceiptType                Environment `json:"receiptType"`
+	AppAppleId                 int64       `json:"appAppleId,omitempty"`
+	BundleId                   string      `json:"bundleId"`
+	ApplicationVersion         string      `json:"applicationVersion"`
+	VersionExternalIdentifier  int64       `json:"versionExternalIdentifier,omitempty"`
+	ReceiptCreationDate        int64       `json:"receiptCreationDate"`
+	OriginalPurchaseDate       int64       `json:"originalPurchaseDate"`
+	OriginalApplicationVersion string      `json:"originalApplicationVersion"`
+	DeviceVerification         string      `json:"deviceVerification"`
+	DeviceVerificationNonce    string      `json:"deviceVerificationNonce"`
+	PreorderDate               int64       `json:"preorderDate,omitempty"`
+	AppTransactionId           string      `json:"appTransactionId"`
+	OriginalPlatform           string      `json:"originalPlatform"`
+}
+
+// ConsumptionRequestReason https://developer.apple.com/documentation/appstoreservernotifications/consumptionrequestreason
+type ConsumptionRequestReason string
+
+const (
+	ConsumptionRequestReasonUnintendedPurchase      ConsumptionRequestReason = "UNINTENDED_PURCHASE"
+	ConsumptionRequestReasonFulfillmentIssue        ConsumptionRequestReason = "FULFILLMENT_ISSUE"
+	ConsumptionRequestReasonUnsatisfiedWithPurchase ConsumptionRequestReason = "UNSATISFIED_WITH_PURCHASE"
+	ConsumptionRequestReasonLegal                   ConsumptionRequestReason = "LEGAL"
+	ConsumptionRequestReasonOther                   ConsumptionRequestReason = "OTHER"
+)
+
+// SubscriptionStatus is the status of an auto-renewable subscription,
+// https://developer.apple.com/documentation/appstoreservernotifications/status
+type SubscriptionStatus int32
+
+const (
+	SubscriptionStatusActive             SubscriptionStatus = 1
+	SubscriptionStatusExpired            SubscriptionStatus = 2
+	SubscriptionStatusBillingRetry       SubscriptionStatus = 3
+	SubscriptionStatusBillingGracePeriod SubscriptionStatus = 4
+	SubscriptionStatusRevoked            SubscriptionStatus = 5
+)
 
 // Notification Transaction Info
 type TransactionInfo struct {
@@ -0,0 +1,128 @@
+package appstore
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNotificationPayload_Decode(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    NotificationPayload
+	}{
+		{
+			name:    "data",
+			payload: `{"notificationType":"CONSUMPTION_REQUEST","notificationUUID":"uuid-1","version":"2.0","signedDate":1698148900000,"data":{"appAppleId":1234,"bundleId":"com.example","bundleVersion":"1.0","consumptionRequestReason":"UNINTENDED_PURCHASE","environment":"Sandbox","signedTransactionInfo":"tx","signedRenewalInfo":"renewal","status":3}}`,
+			want: NotificationPayload{
+				NotificationType: NotificationTypeV2ConsumptionRequest,
+				NotificationUUID: "uuid-1",
+				Version:          "2.0",
+				SignedDate:       1698148900000,
+				Data: NotificationData{
+					AppAppleID:               1234,
+					BundleID:                 "com.example",
+					BundleVersion:            "1.0",
+					ConsumptionRequestReason: ConsumptionRequestReasonUnintendedPurchase,
+					Environment:              Sandbox,
+					SignedTransactionInfo:    "tx",
+					SignedRenewalInfo:        "renewal",
+					Status:                   SubscriptionStatusBillingRetry,
+				},
+			},
+		},
+		{
+			name:    "summary",
+			payload: `{"notificationType":"RENEWAL_EXTENSION","subtype":"SUMMARY","notificationUUID":"uuid-2","signedDate":1698148900000,"summary":{"requestIdentifier":"req-1","environment":"Production","appAppleId":1234,"bundleId":"com.example","productId":"monthly","storefrontCountryCodes":["USA","CAN"],"failedCount":1,"succeededCount":5}}`,
+			want: NotificationPayload{
+				NotificationType: NotificationTypeV2RenewalExtension,
+				Subtype:          SubTypeV2Summary,
+				NotificationUUID: "uuid-2",
+				SignedDate:       1698148900000,
+				Summary: &NotificationSummary{
+					RequestIdentifier:      "req-1",
+					Environment:            Production,
+					AppAppleId:             1234,
+					BundleId:               "com.example",
+					ProductId:              "monthly",
+					StorefrontCountryCodes: []string{"USA", "CAN"},
+					FailedCount:            1,
+					SucceededCount:         5,
+				},
+			},
+		},
+		{
+			name:    "external purchase token",
+			payload: `{"notificationType":"EXTERNAL_PURCHASE_TOKEN","subtype":"UNREPORTED","notificationUUID":"uuid-3","signedDate":1698148900000,"externalPurchaseToken":{"externalPurchaseId":"ext-1","tokenCreationDate":1698148800000,"appAppleId":1234,"bundleId":"com.example"}}`,
+			want: NotificationPayload{
+				NotificationType: NotificationTypeV2ExternalPurchaseToken,
+				Subtype:          SubTypeV2Unreported,
+				NotificationUUID: "uuid-3",
+				SignedDate:       1698148900000,
+				ExternalPurchaseToken: &ExternalPurchaseToken{
+					ExternalPurchaseId: "ext-1",
+					TokenCreationDate:  1698148800000,
+					AppAppleId:         1234,
+					BundleId:           "com.example",
+				},
+			},
+		},
+		{
+			name:    "rescind consent",
+			payload: `{"notificationType":"RESCIND_CONSENT","notificationUUID":"uuid-4","signedDate":1698148900000,"appData":{"appAppleId":1234,"bundleId":"com.example","environment":"Sandbox","signedAppTransactionInfo":"app-tx"}}`,
+			want: NotificationPayload{
+				NotificationType: NotificationTypeV2RescindConsent,
+				NotificationUUID: "uuid-4",
+				SignedDate:       1698148900000,
+				AppData: &NotificationAppData{
+					AppAppleId:               1234,
+					BundleId:                 "com.example",
+					Environment:              Sandbox,
+					SignedAppTransactionInfo: "app-tx",
+				},
+			},
+		},
+		{
+			name:    "metadata update",
+			payload: `{"notificationType":"METADATA_UPDATE","notificationUUID":"uuid-5","signedDate":1698148900000,"data":{"bundleId":"com.example","environment":"Production","signedTransactionInfo":"tx"}}`,
+			want: NotificationPayload{
+				NotificationType: NotificationTypeV2MetadataUpdate,
+				NotificationUUID: "uuid-5",
+				SignedDate:       1698148900000,
+				Data:             NotificationData{BundleID: "com.example", Environment: Production, SignedTransactionInfo: "tx"},
+			},
+		},
+		{
+			name:    "migration",
+			payload: `{"notificationType":"MIGRATION","notificationUUID":"uuid-6","signedDate":1698148900000,"data":{"bundleId":"com.example","environment":"Production","signedTransactionInfo":"tx"}}`,
+			want: NotificationPayload{
+				NotificationType: NotificationTypeV2Migration,
+				NotificationUUID: "uuid-6",
+				SignedDate:       1698148900000,
+				Data:             NotificationData{BundleID: "com.example", Environment: Production, SignedTransactionInfo: "tx"},
+			},
+		},
+		{
+			name:    "price change",
+			payload: `{"notificationType":"PRICE_CHANGE","notificationUUID":"uuid-7","signedDate":1698148900000,"data":{"bundleId":"com.example","environment":"Production","signedTransactionInfo":"tx"}}`,
+			want: NotificationPayload{
+				NotificationType: NotificationTypeV2PriceChange,
+				NotificationUUID: "uuid-7",
+				SignedDate:       1698148900000,
+				Data:             NotificationData{BundleID: "com.example", Environment: Production, SignedTransactionInfo: "tx"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got NotificationPayload
+			if err := json.Unmarshal([]byte(tt.payload), &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
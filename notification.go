@@ -0,0 +1,234 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// DefaultNotificationMaxBodyBytes bounds the body NotificationMux reads. Notifications are a few kilobytes.
+const DefaultNotificationMaxBodyBytes = 1 << 20
+
+var (
+	ErrNotificationBodyTooLarge   = errors.New("appstore notification: body too large")
+	ErrNotificationBundleMismatch = errors.New("appstore notification: bundleId does not match the client")
+)
+
+// Notification is a verified App Store Server Notification with its nested JWS verified and decoded.
+type Notification struct {
+	Payload *NotificationPayload
+	// TransactionInfo and RenewalInfo are decoded from the signedTransactionInfo and signedRenewalInfo of
+	// Payload.Data, and are nil when the notification doesn't carry them.
+	TransactionInfo *JWSTransaction
+	RenewalInfo     *JWSRenewalInfoDecodedPayload
+	// AppTransactionInfo is decoded from the signedAppTransactionInfo of Payload.AppData, nil without it.
+	AppTransactionInfo *JWSAppTransaction
+	// Client is the client that verified the notification, for follow-up API calls.
+	Client *StoreClient
+}
+
+// NotificationHandler handles one notification. Returning an error makes NotificationMux answer 500,
+// so the App Store sends the notification again later.
+type NotificationHandler interface {
+	HandleNotification(ctx context.Context, n *Notification) error
+}
+
+// NotificationHandlerFunc is a function used as a NotificationHandler.
+type NotificationHandlerFunc func(ctx context.Context, n *Notification) error
+
+func (f NotificationHandlerFunc) HandleNotification(ctx context.Context, n *Notification) error {
+	return f(ctx, n)
+}
+
+type NotificationMuxConfig struct {
+	// Client verifies the notifications. Set either Client or Registry.
+	Client *StoreClient
+	// Registry picks the client of the app and environment each notification belongs to.
+	Registry *ClientRegistry
+	// MaxBodyBytes bounds the request body. Default is DefaultNotificationMaxBodyBytes.
+	MaxBodyBytes int64
+	// Fallback handles notifications no registered handler matches. Default acknowledges them.
+	Fallback NotificationHandler
+	// ErrorLog reports requests that are not answered 200. Default discards them.
+	ErrorLog func(format string, v ...any)
+}
+
+// NotificationMux is an http.Handler for the App Store Server Notifications V2 endpoint.
+// It verifies the signed payload and its nested JWS, drops the cached responses of the transaction,
+// then dispatches the notification by type and subtype. Notifications for another bundle ID than the
+// client's are rejected with 400. It answers 200 only once the handler succeeded.
+type NotificationMux struct {
+	client       *StoreClient
+	registry     *ClientRegistry
+	maxBodyBytes int64
+	fallback     NotificationHandler
+	errorLog     func(format string, v ...any)
+
+	mu       sync.RWMutex
+	handlers map[notificationRoute]NotificationHandler
+}
+
+type notificationRoute struct {
+	notificationType NotificationTypeV2
+	subtype          SubtypeV2
+}
+
+func NewNotificationMux(config NotificationMuxConfig) *NotificationMux {
+	maxBodyBytes := config.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultNotificationMaxBodyBytes
+	}
+	errorLog := config.ErrorLog
+	if errorLog == nil {
+		errorLog = func(string, ...any) {}
+	}
+	return &NotificationMux{
+		client:       config.Client,
+		registry:     config.Registry,
+		maxBodyBytes: maxBodyBytes,
+		fallback:     config.Fallback,
+		errorLog:     errorLog,
+		handlers:     make(map[notificationRoute]NotificationHandler),
+	}
+}
+
+// Handle registers h for notificationType with subtype. An empty subtype matches every subtype of
+// notificationType that has no handler of its own.
+func (m *NotificationMux) Handle(notificationType NotificationTypeV2, subtype SubtypeV2, h NotificationHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[notificationRoute{notificationType: notificationType, subtype: subtype}] = h
+}
+
+// HandleFunc registers f like Handle.
+func (m *NotificationMux) HandleFunc(notificationType NotificationTypeV2, subtype SubtypeV2, f func(ctx context.Context, n *Notification) error) {
+	m.Handle(notificationType, subtype, NotificationHandlerFunc(f))
+}
+
+func (m *NotificationMux) handler(notificationType NotificationTypeV2, subtype SubtypeV2) NotificationHandler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if h, ok := m.handlers[notificationRoute{notificationType: notificationType, subtype: subtype}]; ok {
+		return h
+	}
+	if h, ok := m.handlers[notificationRoute{notificationType: notificationType}]; ok {
+		return h
+	}
+	return m.fallback
+}
+
+func (m *NotificationMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		m.fail(w, http.StatusMethodNotAllowed, fmt.Errorf("appstore notification: method %s not allowed", r.Method))
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, m.maxBodyBytes+1))
+	if err != nil {
+		m.fail(w, http.StatusBadRequest, fmt.Errorf("appstore notification: read body err %w", err))
+		return
+	}
+	if int64(len(body)) > m.maxBodyBytes {
+		m.fail(w, http.StatusRequestEntityTooLarge, fmt.Errorf("%w, limit is %d bytes", ErrNotificationBodyTooLarge, m.maxBodyBytes))
+		return
+	}
+
+	n, err := m.decode(body)
+	if err != nil {
+		m.fail(w, http.StatusBadRequest, err)
+		return
+	}
+	if n.TransactionInfo != nil && n.TransactionInfo.OriginalTransactionId != "" {
+		n.Client.InvalidateCache(n.TransactionInfo.OriginalTransactionId)
+	}
+
+	if h := m.handler(n.Payload.NotificationType, n.Payload.Subtype); h != nil {
+		if err = h.HandleNotification(r.Context(), n); err != nil {
+			m.fail(w, http.StatusInternalServerError, fmt.Errorf("appstore notification: handle %s %s %s err %w",
+				n.Payload.NotificationType, n.Payload.Subtype, n.Payload.NotificationUUID, err))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// decode verifies the signed payload of body and the transaction, renewal and app transaction info it
+// carries, and checks that they all belong to the client's app.
+func (m *NotificationMux) decode(body []byte) (*Notification, error) {
+	var req NotificationV2
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("appstore notification: decode body err %w", err)
+	}
+	if req.SignedPayload == "" {
+		return nil, errors.New("appstore notification: body has no signedPayload")
+	}
+
+	client := m.client
+	if m.registry != nil {
+		var err error
+		if client, err = m.registry.ClientForSignedPayload(req.SignedPayload); err != nil {
+			return nil, err
+		}
+	}
+	if client == nil {
+		return nil, errors.New("appstore notification: no client to verify the payload")
+	}
+
+	payload, err := client.ParseNotificationV2Payload(req.SignedPayload)
+	if err != nil {
+		return nil, fmt.Errorf("appstore notification: verify signedPayload err %w", err)
+	}
+	n := &Notification{Payload: payload, Client: client}
+	if s := payload.Data.SignedTransactionInfo; s != "" {
+		if n.TransactionInfo, err = client.ParseNotificationV2TransactionInfo(s); err != nil {
+			return nil, fmt.Errorf("appstore notification: verify signedTransactionInfo err %w", err)
+		}
+	}
+	if s := payload.Data.SignedRenewalInfo; s != "" {
+		if n.RenewalInfo, err = client.ParseNotificationV2RenewalInfo(s); err != nil {
+			return nil, fmt.Errorf("appstore notification: verify signedRenewalInfo err %w", err)
+		}
+	}
+	if payload.AppData != nil && payload.AppData.SignedAppTransactionInfo != "" {
+		if n.AppTransactionInfo, err = client.ParseNotificationV2AppTransactionInfo(payload.AppData.SignedAppTransactionInfo); err != nil {
+			return nil, fmt.Errorf("appstore notification: verify signedAppTransactionInfo err %w", err)
+		}
+	}
+	for _, bundleID := range n.bundleIDs() {
+		if bundleID != "" && bundleID != client.Token.BundleID {
+			return nil, fmt.Errorf("%w: got %s, want %s", ErrNotificationBundleMismatch, bundleID, client.Token.BundleID)
+		}
+	}
+	return n, nil
+}
+
+// bundleIDs returns the bundle IDs the notification names, empty for the parts it doesn't carry.
+func (n *Notification) bundleIDs() []string {
+	ids := []string{n.Payload.Data.BundleID}
+	if n.Payload.Summary != nil {
+		ids = append(ids, n.Payload.Summary.BundleId)
+	}
+	if n.Payload.ExternalPurchaseToken != nil {
+		ids = append(ids, n.Payload.ExternalPurchaseToken.BundleId)
+	}
+	if n.Payload.AppData != nil {
+		ids = append(ids, n.Payload.AppData.BundleId)
+	}
+	if n.TransactionInfo != nil {
+		ids = append(ids, n.TransactionInfo.BundleID)
+	}
+	if n.AppTransactionInfo != nil {
+		ids = append(ids, n.AppTransactionInfo.BundleId)
+	}
+	return ids
+}
+
+func (m *NotificationMux) fail(w http.ResponseWriter, status int, err error) {
+	m.errorLog("%v", err)
+	http.Error(w, http.StatusText(status), status)
+}
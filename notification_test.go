@@ -0,0 +1,293 @@
+package appstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testSigningChain is a locally generated root and leaf certificate standing in for Apple's.
+type testSigningChain struct {
+	roots *x509.CertPool
+	key   *ecdsa.PrivateKey
+	x5c   []string
+}
+
+func newTestSigningChain(t *testing.T) *testSigningChain {
+	t.Helper()
+	newCert := func(template, parent *x509.Certificate, pub *ecdsa.PublicKey, signer *ecdsa.PrivateKey) *x509.Certificate {
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	root := newCert(rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	leaf := newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test Leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, root, &leafKey.PublicKey, rootKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &testSigningChain{
+		roots: roots,
+		key:   leafKey,
+		x5c:   []string{base64.StdEncoding.EncodeToString(leaf.Raw), base64.StdEncoding.EncodeToString(root.Raw)},
+	}
+}
+
+func (c *testSigningChain) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["x5c"] = c.x5c
+	s, err := token.SignedString(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func (c *testSigningChain) notificationBody(t *testing.T, notificationType NotificationTypeV2, subtype SubtypeV2) string {
+	t.Helper()
+	signedPayload := c.sign(t, jwt.MapClaims{
+		"notificationType": notificationType,
+		"subtype":          subtype,
+		"notificationUUID": "uuid-1",
+		"version":          "2.0",
+		"signedDate":       time.Now().UnixMilli(),
+		"data": map[string]any{
+			"bundleId":    "fake.bundle.id",
+			"environment": Sandbox,
+			"signedTransactionInfo": c.sign(t, jwt.MapClaims{
+				"transactionId":         "2000",
+				"originalTransactionId": "1000",
+				"bundleId":              "fake.bundle.id",
+			}),
+			"signedRenewalInfo": c.sign(t, jwt.MapClaims{
+				"originalTransactionId": "1000",
+				"autoRenewStatus":       1,
+			}),
+		},
+	})
+	return notificationBody(t, signedPayload)
+}
+
+func notificationBody(t *testing.T, signedPayload string) string {
+	t.Helper()
+	b, err := json.Marshal(NotificationV2{SignedPayload: signedPayload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestNotificationMux(t *testing.T) {
+	chain := newTestSigningChain(t)
+	config := newTestStoreConfig(t, "")
+	config.TrustedCertPool = chain.roots
+	client := NewStoreClient(config)
+
+	mux := NewNotificationMux(NotificationMuxConfig{
+		Client:       client,
+		MaxBodyBytes: 64 << 10,
+		Fallback: NotificationHandlerFunc(func(ctx context.Context, n *Notification) error {
+			return errors.New("fallback")
+		}),
+	})
+	var got []string
+	mux.HandleFunc(NotificationTypeV2DidRenew, "", func(ctx context.Context, n *Notification) error {
+		if n.TransactionInfo == nil || n.TransactionInfo.OriginalTransactionId != "1000" {
+			t.Errorf("TransactionInfo = %+v, want originalTransactionId 1000", n.TransactionInfo)
+		}
+		if n.RenewalInfo == nil || n.RenewalInfo.AutoRenewStatus != 1 {
+			t.Errorf("RenewalInfo = %+v, want autoRenewStatus 1", n.RenewalInfo)
+		}
+		got = append(got, "renew "+string(n.Payload.Subtype))
+		return nil
+	})
+	mux.HandleFunc(NotificationTypeV2DidRenew, SubTypeV2BillingRecovery, func(ctx context.Context, n *Notification) error {
+		got = append(got, "billing recovery")
+		return nil
+	})
+	mux.HandleFunc(NotificationTypeV2Refund, "", func(ctx context.Context, n *Notification) error {
+		return errors.New("database unavailable")
+	})
+
+	untrusted := newTestSigningChain(t)
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{name: "type handler", method: http.MethodPost, body: chain.notificationBody(t, NotificationTypeV2DidRenew, ""), want: http.StatusOK},
+		{name: "subtype handler", method: http.MethodPost, body: chain.notificationBody(t, NotificationTypeV2DidRenew, SubTypeV2BillingRecovery), want: http.StatusOK},
+		{name: "handler error", method: http.MethodPost, body: chain.notificationBody(t, NotificationTypeV2Refund, ""), want: http.StatusInternalServerError},
+		{name: "fallback", method: http.MethodPost, body: chain.notificationBody(t, NotificationTypeV2Test, ""), want: http.StatusInternalServerError},
+		{name: "untrusted signature", method: http.MethodPost, body: untrusted.notificationBody(t, NotificationTypeV2DidRenew, ""), want: http.StatusBadRequest},
+		{name: "body too large", method: http.MethodPost, body: `{"signedPayload":"` + strings.Repeat("a", 64<<10) + `"}`, want: http.StatusRequestEntityTooLarge},
+		{name: "method", method: http.MethodGet, want: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, "/notifications", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("ServeHTTP() status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+
+	want := []string{"renew ", "billing recovery"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("handled %q, want %q", got, want)
+	}
+}
+
+func TestNotificationMux_Registry(t *testing.T) {
+	chain := newTestSigningChain(t)
+	registry := NewClientRegistry(ClientRegistryConfig{})
+	for _, env := range []Environment{Production, Sandbox} {
+		config := newTestStoreConfig(t, "")
+		config.Environment = env
+		config.TrustedCertPool = chain.roots
+		if err := registry.Register(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sandbox, err := registry.Client("fake.bundle.id", Sandbox)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := NewNotificationMux(NotificationMuxConfig{Registry: registry})
+	var got *Notification
+	mux.HandleFunc(NotificationTypeV2ExternalPurchaseToken, SubTypeV2Unreported, func(ctx context.Context, n *Notification) error {
+		got = n
+		return nil
+	})
+	body := notificationBody(t, chain.sign(t, jwt.MapClaims{
+		"notificationType": NotificationTypeV2ExternalPurchaseToken,
+		"subtype":          SubTypeV2Unreported,
+		"notificationUUID": "uuid-2",
+		"version":          "2.0",
+		"signedDate":       time.Now().UnixMilli(),
+		"externalPurchaseToken": map[string]any{
+			"externalPurchaseId": "SANDBOX_b2f5ea8e-9b1f-4d4e-8d7c-0f4c5a3f1e2d",
+			"tokenCreationDate":  time.Now().UnixMilli(),
+			"appAppleId":         1234,
+			"bundleId":           "fake.bundle.id",
+		},
+	}))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notifications", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ServeHTTP() status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if got == nil || got.Client != sandbox || got.Payload.ExternalPurchaseToken == nil || got.Payload.ExternalPurchaseToken.BundleId != "fake.bundle.id" {
+		t.Errorf("handled %+v, want the external purchase token through the sandbox client", got)
+	}
+}
+
+func TestNotificationMux_AppData(t *testing.T) {
+	chain := newTestSigningChain(t)
+	config := newTestStoreConfig(t, "")
+	config.TrustedCertPool = chain.roots
+	mux := NewNotificationMux(NotificationMuxConfig{Client: NewStoreClient(config)})
+	var got *Notification
+	mux.HandleFunc(NotificationTypeV2RescindConsent, "", func(ctx context.Context, n *Notification) error {
+		got = n
+		return nil
+	})
+
+	untrusted := newTestSigningChain(t)
+	body := func(bundleID string, signer *testSigningChain) string {
+		return notificationBody(t, chain.sign(t, jwt.MapClaims{
+			"notificationType": NotificationTypeV2RescindConsent,
+			"notificationUUID": "uuid-3",
+			"signedDate":       time.Now().UnixMilli(),
+			"appData": map[string]any{
+				"appAppleId":  1234,
+				"bundleId":    bundleID,
+				"environment": Sandbox,
+				"signedAppTransactionInfo": signer.sign(t, jwt.MapClaims{
+					"receiptType":      Sandbox,
+					"bundleId":         bundleID,
+					"appTransactionId": "704",
+				}),
+			},
+		}))
+	}
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "verified", body: body("fake.bundle.id", chain), want: http.StatusOK},
+		{name: "untrusted app transaction", body: body("fake.bundle.id", untrusted), want: http.StatusBadRequest},
+		{name: "other bundle", body: body("other.bundle.id", chain), want: http.StatusBadRequest},
+		{name: "other bundle in data", body: notificationBody(t, chain.sign(t, jwt.MapClaims{
+			"notificationType": NotificationTypeV2DidRenew,
+			"notificationUUID": "uuid-4",
+			"data":             map[string]any{"bundleId": "other.bundle.id", "environment": Sandbox},
+		})), want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/notifications", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("ServeHTTP() status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want != http.StatusOK && got != nil {
+				t.Errorf("handler called for a rejected notification")
+			}
+		})
+	}
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/notifications", strings.NewReader(body("fake.bundle.id", chain))))
+	if got == nil || got.AppTransactionInfo == nil || got.AppTransactionInfo.AppTransactionId != "704" {
+		t.Errorf("handled %+v, want the verified app transaction", got)
+	}
+}
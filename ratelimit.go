@@ -0,0 +1,325 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrRateLimiterClosed = errors.New("appstore rate limiter is closed")
+
+// Rate allows Requests per Per with bursts of up to Burst requests. The zero Rate does not limit.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+	Burst    int // Default is one minute worth of requests.
+}
+
+func (r Rate) perSecond() float64 {
+	if r.Requests <= 0 || r.Per <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Per.Seconds()
+}
+
+func (r Rate) burst() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	if b := r.perSecond() * 60; b > 1 {
+		return b
+	}
+	return 1
+}
+
+// DefaultRateLimits are per-hour quotas for each endpoint, based on the limits Apple publishes at
+// https://developer.apple.com/documentation/appstoreserverapi/identifying_rate_limits.
+// Apple may grant your app different quotas, so tune them with RateLimiterConfig.Limits.
+var DefaultRateLimits = map[Endpoint]Rate{
+	EndpointGetTransactionInfo:                  {Requests: 180000, Per: time.Hour},
+	EndpointLookUpOrderID:                       {Requests: 36000, Per: time.Hour},
+	EndpointGetTransactionHistory:               {Requests: 36000, Per: time.Hour},
+	EndpointGetRefundHistory:                    {Requests: 36000, Per: time.Hour},
+	EndpointGetAllSubscriptionStatuses:          {Requests: 180000, Per: time.Hour},
+	EndpointSendConsumptionInfo:                 {Requests: 36000, Per: time.Hour},
+	EndpointExtendSubscriptionRenewalDate:       {Requests: 36000, Per: time.Hour},
+	EndpointExtendSubscriptionRenewalDateForAll: {Requests: 3600, Per: time.Hour},
+	EndpointGetSubscriptionRenewalDateStatus:    {Requests: 3600, Per: time.Hour},
+	EndpointGetNotificationHistory:              {Requests: 3600, Per: time.Hour},
+	EndpointRequestTestNotification:             {Requests: 3600, Per: time.Hour},
+	EndpointGetTestNotificationStatus:           {Requests: 3600, Per: time.Hour},
+	EndpointSetAppAccountToken:                  {Requests: 36000, Per: time.Hour},
+	EndpointUnknown:                             {Requests: 3600, Per: time.Hour},
+}
+
+type RateLimiterConfig struct {
+	Limits         map[Endpoint]Rate // Quota per endpoint, merged over DefaultRateLimits.
+	SlowdownPeriod time.Duration     // How long an endpoint runs at half rate after a 429. Default is one minute.
+	Clock          Clock             // Default is the system clock.
+}
+
+// RateLimiter keeps a token bucket per endpoint. Buckets start full and refill continuously,
+// so no goroutine is needed and Close only has to release waiting callers.
+type RateLimiter struct {
+	mu       sync.Mutex
+	limits   map[Endpoint]Rate
+	buckets  map[Endpoint]*tokenBucket
+	slowdown time.Duration
+	clock    Clock
+	shared   bool // one bucket for every endpoint, as used by RateLimit
+	closed   bool
+	done     chan struct{}
+}
+
+func NewRateLimiter(config RateLimiterConfig) *RateLimiter {
+	limits := make(map[Endpoint]Rate, len(DefaultRateLimits)+len(config.Limits))
+	for endpoint, rate := range DefaultRateLimits {
+		limits[endpoint] = rate
+	}
+	for endpoint, rate := range config.Limits {
+		limits[endpoint] = rate
+	}
+	slowdown := config.SlowdownPeriod
+	if slowdown <= 0 {
+		slowdown = time.Minute
+	}
+	return &RateLimiter{
+		limits:   limits,
+		buckets:  make(map[Endpoint]*tokenBucket),
+		slowdown: slowdown,
+		clock:    clockOrSystem(config.Clock),
+		done:     make(chan struct{}),
+	}
+}
+
+// clone returns a limiter with the same limits and fresh buckets, for a client with its own quota.
+func (l *RateLimiter) clone() *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return &RateLimiter{
+		limits:   l.limits,
+		buckets:  make(map[Endpoint]*tokenBucket),
+		slowdown: l.slowdown,
+		clock:    l.clock,
+		shared:   l.shared,
+		done:     make(chan struct{}),
+	}
+}
+
+func (l *RateLimiter) bucket(endpoint Endpoint) *tokenBucket {
+	if l.shared {
+		endpoint = EndpointUnknown
+	}
+	if b, ok := l.buckets[endpoint]; ok {
+		return b
+	}
+	rate, ok := l.limits[endpoint]
+	if !ok {
+		rate = l.limits[EndpointUnknown]
+	}
+	b := newTokenBucket(rate, l.clock.Now())
+	l.buckets[endpoint] = b
+	return b
+}
+
+// Wait blocks until the endpoint has capacity for one more request and returns how long it waited.
+// It fails fast when the wait would outlive the context deadline.
+func (l *RateLimiter) Wait(ctx context.Context, endpoint Endpoint) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return 0, ErrRateLimiterClosed
+	}
+	b := l.bucket(endpoint)
+	now := l.clock.Now()
+	delay := b.reserve(now)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return 0, nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		l.cancel(b)
+		return 0, fmt.Errorf("appstore rate limiter: %s needs to wait %v: %w", endpoint, delay, context.DeadlineExceeded)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		l.cancel(b)
+		return 0, ctx.Err()
+	case <-l.done:
+		l.cancel(b)
+		return 0, ErrRateLimiterClosed
+	}
+}
+
+// cancel returns the token of a reservation that was never used. The bucket may have refilled
+// in the meantime, so the token never takes it past its capacity.
+func (l *RateLimiter) cancel(b *tokenBucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b.refill(l.clock.Now())
+	b.tokens++
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// Remaining returns how many requests the endpoint can send right now without waiting.
+func (l *RateLimiter) Remaining(endpoint Endpoint) int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(endpoint)
+	now := l.clock.Now()
+	b.refill(now)
+	if b.tokens < 1 || now.Before(b.pausedUntil) {
+		return 0
+	}
+	return int(b.tokens)
+}
+
+// Throttle slows the endpoint down after the App Store answered with a 429. No request is let through
+// before retryAt, and the bucket refills at half rate for the slowdown period that follows.
+func (l *RateLimiter) Throttle(endpoint Endpoint, retryAt time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(endpoint)
+	now := l.clock.Now()
+	b.refill(now)
+	if retryAt.Before(now) {
+		retryAt = now
+	}
+	if b.tokens > 0 {
+		b.tokens = 0
+	}
+	if retryAt.After(b.pausedUntil) {
+		b.pausedUntil = retryAt
+	}
+	b.slowUntil = retryAt.Add(l.slowdown)
+}
+
+// Close releases callers blocked in Wait. Waiting on a closed limiter returns ErrRateLimiterClosed.
+func (l *RateLimiter) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closed = true
+		close(l.done)
+	}
+	return nil
+}
+
+// observe throttles the endpoint when the response is a 429.
+func (l *RateLimiter) observe(endpoint Endpoint, statusCode int, header http.Header) {
+	if l == nil || statusCode != http.StatusTooManyRequests {
+		return
+	}
+	now := l.clock.Now()
+	retryAt := now.Add(time.Second)
+	if d, ok := retryAfterDelay(header, now); ok {
+		retryAt = now.Add(d)
+	}
+	l.Throttle(endpoint, retryAt)
+}
+
+// SetRateLimiter waits for capacity on the request's endpoint before every request.
+func SetRateLimiter(c HTTPClient, l *RateLimiter) DoFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		endpoint := EndpointFromRequest(req)
+		if _, err := l.Wait(req.Context(), endpoint); err != nil {
+			return nil, err
+		}
+		resp, err := c.Do(req)
+		if resp != nil {
+			l.observe(endpoint, resp.StatusCode, resp.Header)
+		}
+		return resp, err
+	}
+}
+
+type tokenBucket struct {
+	tokens      float64
+	capacity    float64
+	rate        float64 // tokens per second
+	last        time.Time
+	pausedUntil time.Time // no refill before this time after a 429
+	slowUntil   time.Time // refill at half rate until this time after a 429
+}
+
+func newTokenBucket(rate Rate, now time.Time) *tokenBucket {
+	capacity := rate.burst()
+	return &tokenBucket{tokens: capacity, capacity: capacity, rate: rate.perSecond(), last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	from := b.last
+	if from.Before(b.pausedUntil) {
+		from = b.pausedUntil
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+	if !now.After(from) {
+		return
+	}
+
+	var added float64
+	if from.Before(b.slowUntil) {
+		slowTo := now
+		if b.slowUntil.Before(now) {
+			slowTo = b.slowUntil
+		}
+		added += slowTo.Sub(from).Seconds() * b.rate / 2
+		from = slowTo
+	}
+	added += now.Sub(from).Seconds() * b.rate
+
+	b.tokens += added
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// reserve takes a token, possibly going into debt, and returns how long the caller must wait for it.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+	b.refill(now)
+	b.tokens--
+
+	var wait time.Duration
+	start := now
+	if now.Before(b.pausedUntil) {
+		wait = b.pausedUntil.Sub(now)
+		start = b.pausedUntil
+	}
+	if b.tokens < 0 {
+		rate := b.rate
+		if start.Before(b.slowUntil) {
+			rate /= 2
+		}
+		wait += time.Duration(-b.tokens / rate * float64(time.Second))
+	}
+	return wait
+}
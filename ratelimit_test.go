@@ -0,0 +1,115 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestRateLimiter(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := NewRateLimiter(RateLimiterConfig{
+		Limits: map[Endpoint]Rate{EndpointGetTransactionHistory: {Requests: 3600, Per: time.Hour, Burst: 2}},
+		Clock:  clock,
+	})
+	defer l.Close()
+
+	if got := l.Remaining(EndpointGetTransactionHistory); got != 2 {
+		t.Fatalf("Remaining() = %d, want a full bucket of 2", got)
+	}
+	if got := l.Remaining(EndpointGetAllSubscriptionStatuses); got != 3000 {
+		t.Errorf("Remaining() = %d, want the default burst of 3000 for a separate endpoint", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		if wait, err := l.Wait(context.TODO(), EndpointGetTransactionHistory); wait != 0 || err != nil {
+			t.Fatalf("Wait() = %v, %v, want no wait", wait, err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx, EndpointGetTransactionHistory); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() error = %v, want to fail fast on an empty bucket", err)
+	}
+
+	clock.Advance(time.Second)
+	if got := l.Remaining(EndpointGetTransactionHistory); got != 1 {
+		t.Errorf("Remaining() = %d, want 1 after refilling for a second", got)
+	}
+
+	l.Throttle(EndpointGetTransactionHistory, clock.Now().Add(10*time.Second))
+	clock.Advance(5 * time.Second)
+	if got := l.Remaining(EndpointGetTransactionHistory); got != 0 {
+		t.Errorf("Remaining() = %d, want 0 before Retry-After", got)
+	}
+	clock.Advance(7 * time.Second)
+	if got := l.Remaining(EndpointGetTransactionHistory); got != 1 {
+		t.Errorf("Remaining() = %d, want 1 after refilling at half rate for 2 seconds", got)
+	}
+
+	l.Close()
+	if _, err := l.Wait(context.TODO(), EndpointGetTransactionHistory); !errors.Is(err, ErrRateLimiterClosed) {
+		t.Errorf("Wait() error = %v, want ErrRateLimiterClosed", err)
+	}
+}
+
+func TestRateLimiter_Cancel(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	l := NewRateLimiter(RateLimiterConfig{
+		Limits: map[Endpoint]Rate{EndpointGetTransactionHistory: {Requests: 3600, Per: time.Hour, Burst: 2}},
+		Clock:  clock,
+	})
+	defer l.Close()
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Wait(context.TODO(), EndpointGetTransactionHistory); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	done := make(chan error)
+	go func() {
+		_, err := l.Wait(ctx, EndpointGetTransactionHistory)
+		done <- err
+	}()
+	// The bucket goes into debt once the waiter reserved its token.
+	for {
+		l.mu.Lock()
+		reserved := l.buckets[EndpointGetTransactionHistory].tokens < 0
+		l.mu.Unlock()
+		if reserved {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+	if got := l.Remaining(EndpointGetTransactionHistory); got != 2 {
+		t.Fatalf("Remaining() = %d, want a full bucket of 2", got)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait() error = %v, want context.Canceled", err)
+	}
+	if got := l.Remaining(EndpointGetTransactionHistory); got != 2 {
+		t.Errorf("Remaining() = %d after cancelling a reservation, want the capacity of 2", got)
+	}
+}
@@ -0,0 +1,151 @@
+package appstore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrClientNotRegistered     = errors.New("appstore registry: no client registered for the bundle ID and environment")
+	ErrClientAlreadyRegistered = errors.New("appstore registry: a client is already registered for the bundle ID and environment")
+)
+
+type ClientRegistryConfig struct {
+	// HTTPClient is shared by every client, so the apps share one transport and its connection pool.
+	// Default is an http.Client with a 30 second timeout.
+	HTTPClient HTTPClient
+	// RateLimits gives each app and environment its own RateLimiter built from it, unless the app's
+	// StoreConfig sets one. Default is no client-side limit.
+	RateLimits *RateLimiterConfig
+}
+
+// ClientRegistry holds the StoreConfig of several apps and routes calls to the app's StoreClient
+// by bundle ID and environment. Clients are created on first use.
+type ClientRegistry struct {
+	mu         sync.Mutex
+	httpClient HTTPClient
+	rateLimits *RateLimiterConfig
+	configs    map[registryKey]*StoreConfig
+	clients    map[registryKey]*StoreClient
+}
+
+type registryKey struct {
+	bundleID    string
+	environment Environment
+}
+
+func NewClientRegistry(config ClientRegistryConfig) *ClientRegistry {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout: 30 * time.Second,
+		}
+	}
+	return &ClientRegistry{
+		httpClient: httpClient,
+		rateLimits: config.RateLimits,
+		configs:    make(map[registryKey]*StoreConfig),
+		clients:    make(map[registryKey]*StoreClient),
+	}
+}
+
+// Register validates config and adds it under its bundle ID and environment.
+// Register the sandbox and production configs of an app separately.
+func (r *ClientRegistry) Register(config *StoreConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	cp := *config
+	key := registryKey{bundleID: cp.BundleID, environment: cp.environment()}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.configs[key]; ok {
+		return fmt.Errorf("%w: %s in %s", ErrClientAlreadyRegistered, key.bundleID, key.environment)
+	}
+	r.configs[key] = &cp
+	return nil
+}
+
+// Client returns the client of the app in environment, creating it on first use.
+func (r *ClientRegistry) Client(bundleID string, environment Environment) (*StoreClient, error) {
+	key := registryKey{bundleID: bundleID, environment: environment}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.clients[key]; ok {
+		return c, nil
+	}
+	config, ok := r.configs[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s in %s", ErrClientNotRegistered, bundleID, environment)
+	}
+	if config.RateLimiter == nil && r.rateLimits != nil {
+		config.RateLimiter = NewRateLimiter(*r.rateLimits)
+	}
+	c := NewStoreClientWithHTTPClient(config, r.httpClient)
+	r.clients[key] = c
+	return c, nil
+}
+
+// ClientForSignedPayload returns the client of the app a signed notification or transaction belongs to,
+// going by its bundleId and environment. The payload is only decoded here; verify it with the returned
+// client, such as with ParseNotificationV2.
+func (r *ClientRegistry) ClientForSignedPayload(signedPayload string) (*StoreClient, error) {
+	bundleID, environment, err := signedPayloadApp(signedPayload)
+	if err != nil {
+		return nil, err
+	}
+	return r.Client(bundleID, environment)
+}
+
+// signedPayloadApp reads the bundleId and environment of a notification, whose data, summary, externalPurchaseToken
+// or appData carries them, or of a signed transaction or renewal info, which carries them at the top level.
+func signedPayloadApp(signedPayload string) (string, Environment, error) {
+	parts := strings.Split(signedPayload, ".")
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("appstore registry: signed payload is not a JWS")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", fmt.Errorf("appstore registry: decode signed payload err %w", err)
+	}
+
+	type app struct {
+		BundleId           string      `json:"bundleId"`
+		Environment        Environment `json:"environment"`
+		ExternalPurchaseId string      `json:"externalPurchaseId"`
+	}
+	var payload struct {
+		app
+		Data                  *app `json:"data"`
+		Summary               *app `json:"summary"`
+		ExternalPurchaseToken *app `json:"externalPurchaseToken"`
+		AppData               *app `json:"appData"`
+	}
+	if err = json.Unmarshal(b, &payload); err != nil {
+		return "", "", fmt.Errorf("appstore registry: decode signed payload err %w", err)
+	}
+	found := payload.app
+	for _, nested := range []*app{payload.Data, payload.Summary, payload.ExternalPurchaseToken, payload.AppData} {
+		if found.BundleId == "" && nested != nil {
+			found = *nested
+		}
+	}
+	if found.BundleId == "" {
+		return "", "", fmt.Errorf("appstore registry: signed payload has no bundleId")
+	}
+	if found.Environment == "" && strings.HasPrefix(found.ExternalPurchaseId, "SANDBOX") {
+		// External purchase tokens carry no environment; sandbox ones are told apart by their ID.
+		found.Environment = Sandbox
+	} else if found.Environment == "" {
+		found.Environment = Production
+	}
+	return found.BundleId, found.Environment, nil
+}
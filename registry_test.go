@@ -0,0 +1,58 @@
+package appstore
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestClientRegistry(t *testing.T) {
+	r := NewClientRegistry(ClientRegistryConfig{RateLimits: &RateLimiterConfig{}})
+
+	production := newTestStoreConfig(t, "")
+	sandbox := newTestStoreConfig(t, "")
+	sandbox.Environment = Sandbox
+	other := newTestStoreConfig(t, "")
+	other.BundleID = "other.bundle.id"
+	for _, c := range []*StoreConfig{production, sandbox, other} {
+		if err := r.Register(c); err != nil {
+			t.Fatalf("Register(%v) error = %v", c, err)
+		}
+	}
+	if err := r.Register(production); !errors.Is(err, ErrClientAlreadyRegistered) {
+		t.Errorf("Register() error = %v, want ErrClientAlreadyRegistered", err)
+	}
+
+	c1, err := r.Client("fake.bundle.id", Sandbox)
+	if err != nil || c1.Environment() != Sandbox {
+		t.Fatalf("Client() = %v, %v", c1, err)
+	}
+	c2, _ := r.Client("fake.bundle.id", Sandbox)
+	c3, _ := r.Client("other.bundle.id", Production)
+	if c1 != c2 || c3 == nil || c3.Token.BundleID != "other.bundle.id" {
+		t.Errorf("Client() did not reuse clients per app and environment")
+	}
+	if c1.limiter == nil || c1.limiter == c3.limiter || c1.httpCli != c3.httpCli {
+		t.Errorf("clients should share the HTTP client but not the rate limiter")
+	}
+	if _, err = r.Client("unknown", Production); !errors.Is(err, ErrClientNotRegistered) {
+		t.Errorf("Client() error = %v, want ErrClientNotRegistered", err)
+	}
+
+	jws := func(payload string) string {
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".sig"
+	}
+	c0, _ := r.Client("fake.bundle.id", Production)
+	for payload, want := range map[string]*StoreClient{
+		`{"notificationType":"TEST","data":{"bundleId":"fake.bundle.id","environment":"Sandbox"}}`: c1,
+		`{"notificationType":"RENEWAL_EXTENSION","summary":{"bundleId":"other.bundle.id"}}`:        c3,
+		`{"transactionId":"1","bundleId":"fake.bundle.id","environment":"Production"}`:             c0,
+	} {
+		if got, err := r.ClientForSignedPayload(jws(payload)); got != want {
+			t.Errorf("ClientForSignedPayload(%s) = %v, %v", payload, got, err)
+		}
+	}
+	if _, err = r.ClientForSignedPayload(jws(`{"bundleId":"missing.bundle.id"}`)); !errors.Is(err, ErrClientNotRegistered) {
+		t.Errorf("ClientForSignedPayload() error = %v, want ErrClientNotRegistered", err)
+	}
+}
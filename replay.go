@@ -0,0 +1,221 @@
+package appstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+var ErrNoRecording = errors.New("appstore replay: no recorded interaction matches the request")
+
+const redactedValue = "REDACTED"
+
+// Interaction is a recorded request and the response it got.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"` // Encoded with sorted keys.
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+type fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type RecorderOptions struct {
+	// Redact replaces every occurrence of a key with its value in recorded paths, queries, headers and bodies,
+	// such as a real transaction ID with a placeholder the replaying test passes instead.
+	Redact map[string]string
+	// RedactHeaders are recorded as REDACTED. Authorization and cookies are always redacted.
+	RedactHeaders []string
+}
+
+// Recorder is an HTTPClient that forwards requests to next and keeps every interaction for Save.
+// Pass it to NewStoreClientWithHTTPClient to capture real sandbox traffic as a fixture for Replayer.
+type Recorder struct {
+	next         HTTPClient
+	path         string
+	opts         RecorderOptions
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder records the traffic of next into the fixture file at path once Save is called.
+func NewRecorder(next HTTPClient, path string, opts RecorderOptions) *Recorder {
+	return &Recorder{next: next, path: path, opts: opts}
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	resp, err := r.next.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	rspBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(rspBody))
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Path:   r.redact(req.URL.Path),
+			Query:  r.redact(normalizeQuery(req.URL.RawQuery)),
+			Header: r.redactHeader(req.Header),
+			Body:   r.redact(string(reqBody)),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.redactHeader(resp.Header),
+			Body:       r.redact(string(rspBody)),
+		},
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) redact(s string) string {
+	for from, to := range r.opts.Redact {
+		if from != "" {
+			s = strings.Replace(s, from, to, -1)
+		}
+	}
+	return s
+}
+
+func (r *Recorder) redactHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, vs := range h {
+		for _, v := range vs {
+			out.Add(k, r.redact(v))
+		}
+	}
+	return RedactHeader(out, r.opts.RedactHeaders...)
+}
+
+// Interactions returns the interactions recorded so far.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Save writes the recorded interactions to the fixture file.
+func (r *Recorder) Save() error {
+	b, err := json.MarshalIndent(fixture{Interactions: r.Interactions()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, b, 0644)
+}
+
+// Replayer is an HTTPClient that answers requests from a fixture written by Recorder, without any network access.
+// Requests match an interaction by method, path and query regardless of parameter order. Matching interactions
+// are replayed in recorded order, and the last one keeps answering once they are used up.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer loads the fixture file at path.
+func NewReplayer(path string) (*Replayer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f fixture
+	if err = json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("appstore replay: parse fixture %s err %w", path, err)
+	}
+	return NewReplayerFromInteractions(f.Interactions), nil
+}
+
+func NewReplayerFromInteractions(interactions []Interaction) *Replayer {
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions))}
+}
+
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	query := normalizeQuery(req.URL.RawQuery)
+
+	r.mu.Lock()
+	match := -1
+	for i, in := range r.interactions {
+		if in.Request.Method != req.Method || in.Request.Path != req.URL.Path || in.Request.Query != query {
+			continue
+		}
+		match = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if match >= 0 {
+		r.used[match] = true
+	}
+	r.mu.Unlock()
+
+	if match < 0 {
+		return nil, fmt.Errorf("%w: %s %s?%s among %d interactions", ErrNoRecording, req.Method, req.URL.Path, query, len(r.interactions))
+	}
+
+	rec := r.interactions[match].Response
+	header := rec.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.StatusCode, http.StatusText(rec.StatusCode)),
+		StatusCode:    rec.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}, nil
+}
+
+// normalizeQuery sorts the parameters of a raw query so equivalent queries compare equal.
+func normalizeQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	return values.Encode()
+}
@@ -0,0 +1,49 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorderReplayer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"environment":"Sandbox","bundleId":"fake.bundle.id","hasMore":false,"signedTransactions":["jws-2000000123"]}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "history.json")
+	rec := NewRecorder(srv.Client(), path, RecorderOptions{Redact: map[string]string{"2000000123": "ORIGINAL_TRANSACTION_ID"}})
+	c := newTestStoreConfig(t, srv.URL)
+	if _, err := NewStoreClientWithHTTPClient(c, rec).GetTransactionHistory(context.TODO(), "2000000123", nil); err != nil {
+		t.Fatalf("GetTransactionHistory() recording error = %v", err)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	b, _ := os.ReadFile(path)
+	if strings.Contains(string(b), "2000000123") || strings.Contains(string(b), "Bearer") {
+		t.Fatalf("fixture leaks redacted values: %s", b)
+	}
+
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("NewReplayer() error = %v", err)
+	}
+	a := NewStoreClientWithHTTPClient(c, replayer)
+	rsp, err := a.GetTransactionHistory(context.TODO(), "ORIGINAL_TRANSACTION_ID", nil)
+	if err != nil || len(rsp) != 1 || rsp[0].SignedTransactions[0] != "jws-ORIGINAL_TRANSACTION_ID" {
+		t.Fatalf("GetTransactionHistory() replay rsp = %v, error = %v", rsp, err)
+	}
+
+	if _, err = a.GetTransactionInfo(context.TODO(), "ORIGINAL_TRANSACTION_ID"); !errors.Is(err, ErrNoRecording) {
+		t.Errorf("GetTransactionInfo() error = %v, want ErrNoRecording", err)
+	}
+}
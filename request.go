@@ -0,0 +1,271 @@
+package appstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Endpoint names an App Store Server API operation independently of the identifiers in its URL.
+type Endpoint string
+
+const (
+	EndpointGetTransactionInfo                  Endpoint = "GetTransactionInfo"
+	EndpointLookUpOrderID                       Endpoint = "LookUpOrderID"
+	EndpointGetTransactionHistory               Endpoint = "GetTransactionHistory"
+	EndpointGetRefundHistory                    Endpoint = "GetRefundHistory"
+	EndpointGetAllSubscriptionStatuses          Endpoint = "GetAllSubscriptionStatuses"
+	EndpointSendConsumptionInfo                 Endpoint = "SendConsumptionInfo"
+	EndpointExtendSubscriptionRenewalDate       Endpoint = "ExtendSubscriptionRenewalDate"
+	EndpointExtendSubscriptionRenewalDateForAll Endpoint = "ExtendSubscriptionRenewalDateForAll"
+	EndpointGetSubscriptionRenewalDateStatus    Endpoint = "GetSubscriptionRenewalDateStatus"
+	EndpointGetNotificationHistory              Endpoint = "GetNotificationHistory"
+	EndpointRequestTestNotification             Endpoint = "RequestTestNotification"
+	EndpointGetTestNotificationStatus           Endpoint = "GetTestNotificationStatus"
+	EndpointSetAppAccountToken                  Endpoint = "SetAppAccountToken"
+	EndpointUnknown                             Endpoint = "Unknown"
+)
+
+var endpointRoutes = []struct {
+	endpoint Endpoint
+	method   string
+	path     string
+}{
+	{EndpointGetTransactionInfo, http.MethodGet, PathTransactionInfo},
+	{EndpointLookUpOrderID, http.MethodGet, PathLookUp},
+	{EndpointGetTransactionHistory, http.MethodGet, PathTransactionHistory},
+	{EndpointGetRefundHistory, http.MethodGet, PathRefundHistory},
+	{EndpointGetAllSubscriptionStatuses, http.MethodGet, PathGetALLSubscriptionStatus},
+	{EndpointSendConsumptionInfo, http.MethodPut, PathConsumptionInfo},
+	{EndpointExtendSubscriptionRenewalDate, http.MethodPut, PathExtendSubscriptionRenewalDate},
+	{EndpointExtendSubscriptionRenewalDateForAll, http.MethodPost, PathExtendSubscriptionRenewalDateForAll},
+	{EndpointGetSubscriptionRenewalDateStatus, http.MethodGet, PathGetStatusOfSubscriptionRenewalDate},
+	{EndpointGetNotificationHistory, http.MethodPost, PathGetNotificationHistory},
+	{EndpointRequestTestNotification, http.MethodPost, PathRequestTestNotification},
+	{EndpointGetTestNotificationStatus, http.MethodGet, PathGetTestNotificationStatus},
+	{EndpointSetAppAccountToken, http.MethodPut, PathSetAppAccountToken},
+}
+
+type endpointContextKey struct{}
+
+func contextWithEndpoint(ctx context.Context, endpoint Endpoint) context.Context {
+	return context.WithValue(ctx, endpointContextKey{}, endpoint)
+}
+
+// EndpointFromRequest returns the endpoint a request was issued for, so middlewares in the HTTPClient chain
+// can key their state by operation rather than by raw URL. Requests built outside StoreClient are matched
+// against the known path templates.
+func EndpointFromRequest(req *http.Request) Endpoint {
+	if endpoint, ok := req.Context().Value(endpointContextKey{}).(Endpoint); ok {
+		return endpoint
+	}
+	return matchEndpoint(req.Method, req.URL.Path)
+}
+
+// matchEndpoint matches the trailing segments of path against the endpoint templates,
+// which keeps a path prefix from a custom BaseURL out of the way.
+func matchEndpoint(method, path string) Endpoint {
+	segments := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	for _, route := range endpointRoutes {
+		if route.method != method {
+			continue
+		}
+		tmpl := strings.Split(strings.TrimSuffix(route.path, "/"), "/")[1:]
+		if len(segments) < len(tmpl) {
+			continue
+		}
+		tail := segments[len(segments)-len(tmpl):]
+		matched := true
+		for i, s := range tmpl {
+			if !strings.HasPrefix(s, "{") && s != tail[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return route.endpoint
+		}
+	}
+	return EndpointUnknown
+}
+
+// apiRequest describes a single App Store Server API call.
+type apiRequest struct {
+	endpoint   Endpoint
+	method     string
+	path       string
+	pathParams map[string]string
+	query      url.Values
+	body       any  // encoded as JSON when not nil
+	idempotent bool // safe to retry after a server error, on top of the idempotent HTTP methods
+	probe      bool // a health check: uncached, and a 401 neither refreshes the token nor rejects its key
+}
+
+// errorBody returns the raw body of the error response behind err, nil when there was no response.
+func errorBody(err error) []byte {
+	var respErr *Error
+	if errors.As(err, &respErr) {
+		return respErr.RawBody()
+	}
+	return nil
+}
+
+// execute sends r and decodes a successful JSON response into a new T.
+func execute[T any](ctx context.Context, c *StoreClient, r *apiRequest) (*T, int, error) {
+	var body []byte
+	if r.body != nil {
+		b, err := json.Marshal(r.body)
+		if err != nil {
+			return nil, 0, fmt.Errorf("appstore encode %s request err %w", r.endpoint, err)
+		}
+		body = b
+	}
+
+	if c.hostUrl == "" {
+		return nil, 0, fmt.Errorf("%w for the %s environment", ErrBaseURLRequired, c.environment)
+	}
+	idempotent := r.idempotent || isIdempotentMethod(r.method)
+	URL := c.buildURL(r.path, r.pathParams, r.query)
+	send := func(ctx context.Context) (int, []byte, error) {
+		return c.do(ctx, r.endpoint, r.method, URL, body, idempotent, !r.probe)
+	}
+
+	var ttl time.Duration
+	if !r.probe {
+		ttl = c.cacheTTL(r.endpoint)
+	}
+	// URL carries the host, and the environment tells apart clients that share a BaseURL, such as behind a proxy.
+	cacheKey := c.Token.BundleID + " " + string(c.environment) + " " + r.method + " " + URL
+	var statusCode int
+	var rspBody []byte
+	var err error
+	if cached, ok := c.cacheGet(ttl, cacheKey); ok {
+		statusCode, rspBody = http.StatusOK, cached
+	} else {
+		var gen uint64
+		if ttl > 0 {
+			gen = c.cacheGeneration()
+		}
+		if c.coalescer != nil && r.method == http.MethodGet {
+			statusCode, rspBody, err = c.coalescer.do(ctx, r.method+" "+URL, send)
+		} else {
+			statusCode, rspBody, err = send(ctx)
+		}
+		if err != nil {
+			return nil, statusCode, err
+		}
+		if ttl > 0 && c.cacheGeneration() == gen {
+			c.cache.Set(cacheKey, rspBody, ttl, c.cacheTags(r, rspBody))
+		}
+	}
+
+	rsp := new(T)
+	if len(rspBody) > 0 {
+		if err = json.Unmarshal(rspBody, rsp); err != nil {
+			return nil, statusCode, fmt.Errorf("appstore decode %s response err %w", r.endpoint, err)
+		}
+	}
+	return rsp, statusCode, nil
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// do sends the request, retrying it as the client's RetryPolicy allows. With refresh, a 401 regenerates
+// the token once, which also reports the key to the KeyProvider, and sends the request again.
+func (c *StoreClient) do(ctx context.Context, endpoint Endpoint, method, URL string, body []byte, idempotent, refresh bool) (int, []byte, error) {
+	bo := c.retry.newBackoff()
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		authToken, err := c.Token.GenerateIfExpired()
+		if err != nil {
+			return 0, nil, fmt.Errorf("appstore generate token err %w", err)
+		}
+
+		info := &RequestInfo{Endpoint: endpoint, Method: method, Attempt: attempt}
+		if info.RateLimitWait, err = c.limiter.Wait(ctx, endpoint); err != nil {
+			return 0, nil, err
+		}
+
+		reqCtx := ctx
+		if c.instrumentation != nil {
+			reqCtx = c.instrumentation.BeforeRequest(ctx, info)
+		}
+		start := time.Now()
+		statusCode, rspBody, header, err := c.doOnce(reqCtx, endpoint, method, URL, body, authToken)
+		c.limiter.observe(endpoint, statusCode, header)
+		if c.instrumentation != nil {
+			info.StatusCode, info.ErrorCode, info.Duration, info.Err = statusCode, apiErrorCode(err), time.Since(start), err
+			c.instrumentation.AfterRequest(reqCtx, info)
+		}
+		if statusCode == http.StatusUnauthorized {
+			// A 401 rejects the token rather than the request, so regenerate it once and try again.
+			if refresh && !refreshed && ctx.Err() == nil {
+				refreshed = true
+				if _, err = c.Token.refresh(authToken); err != nil {
+					return 0, nil, fmt.Errorf("appstore generate token err %w", err)
+				}
+				continue
+			}
+			var respErr *Error
+			if errors.As(err, &respErr) {
+				err = newAuthenticationError(respErr, authToken, c.Token.KeyType, refreshed)
+			}
+			return statusCode, rspBody, err
+		}
+		if attempt >= c.retry.maxAttempts() || !c.retry.shouldRetry(ctx, idempotent, statusCode, err) {
+			return statusCode, rspBody, err
+		}
+		if !c.retry.wait(ctx, bo, header) {
+			return statusCode, rspBody, err
+		}
+	}
+}
+
+// doOnce performs one authorized request and maps non-2xx responses to an error.
+func (c *StoreClient) doOnce(ctx context.Context, endpoint Endpoint, method, URL string, body []byte, authToken string) (int, []byte, http.Header, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(contextWithEndpoint(ctx, endpoint), method, URL, reqBody)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("appstore new http request err %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+authToken)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "App Store Client")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpCli.Do(req)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("appstore http client do err %w", err)
+	}
+	defer resp.Body.Close()
+
+	byteData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, resp.Header, fmt.Errorf("appstore read http body err %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, byteData, resp.Header, newResponseError(endpoint, resp.StatusCode, resp.Header, byteData)
+	}
+
+	return resp.StatusCode, byteData, resp.Header, nil
+}
@@ -0,0 +1,128 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryableErrorCodes are the App Store Server API error codes that ask the caller to try again.
+var retryableErrorCodes = map[int]bool{
+	AccountNotFoundRetryableError.errorCode:               true,
+	AppNotFoundRetryableError.errorCode:                   true,
+	OriginalTransactionIdNotFoundRetryableError.errorCode: true,
+	GeneralInternalRetryableError.errorCode:               true,
+	RateLimitExceededError.errorCode:                      true,
+}
+
+// RetryPolicy controls how StoreClient retries a failed request.
+// Rate-limited requests are retried for every method. Retryable error codes, other server errors
+// and transport failures are retried only for idempotent calls, since the App Store may have acted on them.
+//
+// Pauses are slept on real timers, while the MaxElapsed of a backoff counts time on the backoff's Clock.
+// With a fake Clock, only the time the caller advances it by counts towards MaxElapsed, not the pauses slept.
+type RetryPolicy struct {
+	MaxAttempts   int            // Total attempts including the first one. Default is 3.
+	Backoff       BackoffFactory // Creates the pauses between attempts of a request when the response has no Retry-After. Default is JitterBackoff.
+	MaxRetryAfter time.Duration  // Longest Retry-After wait to honor before giving up. Default is no limit besides the context deadline.
+}
+
+// DefaultRetryPolicy retries up to three attempts with jittered exponential backoff.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 3}
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil {
+		return 1
+	}
+	if p.MaxAttempts <= 0 {
+		return 3
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) newBackoff() Backoff {
+	if p == nil || p.Backoff == nil {
+		return &JitterBackoff{}
+	}
+	return p.Backoff.NewBackoff()
+}
+
+// shouldRetry decides whether an attempt that finished with statusCode and err is worth repeating.
+func (p *RetryPolicy) shouldRetry(ctx context.Context, idempotent bool, statusCode int, err error) bool {
+	if err == nil || ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	// A 429 was turned away before the App Store acted on it, so it is safe to repeat for every method.
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if !idempotent {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) && retryableErrorCodes[apiErr.errorCode] {
+		return true
+	}
+	// A zero status code means the request never got a response, such as a reset connection.
+	return statusCode == 0 || ShouldRetryDefault(statusCode, err)
+}
+
+// wait pauses before the next attempt, preferring the Retry-After time of a 429 response over the backoff.
+// It reports false when the pause would outlive the context deadline or the policy limits.
+func (p *RetryPolicy) wait(ctx context.Context, bo Backoff, header http.Header) bool {
+	pause, ok := retryAfterDelay(header, time.Now())
+	if ok {
+		if p.MaxRetryAfter > 0 && pause > p.MaxRetryAfter {
+			return false
+		}
+	} else {
+		pause = bo.Pause()
+		if pause < 0 {
+			return false
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(pause).After(deadline) {
+		return false
+	}
+
+	timer := time.NewTimer(pause)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// retryAfterDelay converts the Retry-After response header into a wait duration.
+// Apple sends a UNIX time in milliseconds, the delay-seconds and HTTP-date forms are accepted as well.
+func retryAfterDelay(header http.Header, now time.Time) (time.Duration, bool) {
+	v := header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+
+	var at time.Time
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if n < 1e11 {
+			// Too small to be a UNIX time in milliseconds, so it is a number of seconds.
+			return time.Duration(n) * time.Second, true
+		}
+		at = time.UnixMilli(n)
+	} else if t, err := http.ParseTime(v); err == nil {
+		at = t
+	} else {
+		return 0, false
+	}
+
+	if d := at.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
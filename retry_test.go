@@ -0,0 +1,89 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterDelay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{"unix millis", strconv.FormatInt(now.Add(1500*time.Millisecond).UnixMilli(), 10), 1500 * time.Millisecond, true},
+		{"past unix millis", strconv.FormatInt(now.Add(-time.Second).UnixMilli(), 10), 0, true},
+		{"seconds", "2", 2 * time.Second, true},
+		{"http date", now.Add(3 * time.Second).UTC().Format(http.TimeFormat), 3 * time.Second, true},
+		{"missing", "", 0, false},
+		{"invalid", "soon", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Retry-After", tt.value)
+			}
+			got, ok := retryAfterDelay(header, now)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("retryAfterDelay() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestStoreClient_RetryPolicy(t *testing.T) {
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == PathExtendSubscriptionRenewalDateForAll:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errorCode":5000001,"errorMessage":"An unknown error occurred. Please try again."}`))
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"errorCode":5000000,"errorMessage":"An unknown error occurred."}`))
+		case attempts == 1:
+			w.Header().Set("Retry-After", strconv.FormatInt(time.Now().Add(20*time.Millisecond).UnixMilli(), 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"errorCode":4290000,"errorMessage":"Rate limit exceeded."}`))
+		default:
+			w.Write([]byte(`{"signedTransactionInfo":"jws"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := newTestStoreConfig(t, srv.URL)
+	c.RetryPolicy = &RetryPolicy{MaxAttempts: 3, Backoff: &OneSecondBackoff{}}
+	a := NewStoreClient(c)
+
+	rsp, err := a.GetTransactionInfo(context.TODO(), "1000")
+	if err != nil || rsp.SignedTransactionInfo != "jws" || attempts != 2 {
+		t.Fatalf("GetTransactionInfo() rsp = %v, error = %v, attempts = %d", rsp, err, attempts)
+	}
+
+	attempts = 0
+	if _, _, err = a.SendRequestTestNotification(context.TODO()); err == nil || attempts != 1 {
+		t.Errorf("SendRequestTestNotification() error = %v, attempts = %d, want a single attempt", err, attempts)
+	}
+
+	attempts = 0
+	if _, err = a.ExtendSubscriptionRenewalDateForAll(context.TODO(), MassExtendRenewalDateRequest{RequestIdentifier: "req-1"}); err == nil || attempts != 1 {
+		t.Errorf("ExtendSubscriptionRenewalDateForAll() error = %v, attempts = %d, want a single attempt despite the retryable code", err, attempts)
+	}
+
+	attempts = 0
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	c.RetryPolicy.MaxRetryAfter = time.Hour
+	if _, err = NewStoreClient(c).GetTransactionInfo(ctx, "1000"); !errors.Is(err, RateLimitExceededError) || attempts != 1 {
+		t.Errorf("GetTransactionInfo() error = %v, attempts = %d, want to give up before the deadline", err, attempts)
+	}
+}
@@ -0,0 +1,80 @@
+package appstore
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var ErrSignerInvalidKey = errors.New("token: signer key must be an ECDSA P-256 key")
+
+// Signer signs the JWT signing input of the App Store Server API bearer token with ES256,
+// returning the 64-byte r||s signature. Implement it to keep the .p8 key inside an HSM or KMS.
+type Signer interface {
+	Sign(signingInput []byte) ([]byte, error)
+}
+
+// SignerFunc adapts a function to the Signer interface.
+type SignerFunc func(signingInput []byte) ([]byte, error)
+
+func (f SignerFunc) Sign(signingInput []byte) ([]byte, error) {
+	return f(signingInput)
+}
+
+// NewKeySigner signs with the .p8 private key in keyContent, held in memory.
+func NewKeySigner(keyContent []byte) (Signer, error) {
+	key, err := (&Token{}).passKeyFromByte(keyContent)
+	if err != nil {
+		return nil, err
+	}
+	return NewCryptoSigner(key)
+}
+
+type cryptoSigner struct {
+	signer crypto.Signer
+}
+
+// NewCryptoSigner signs through a crypto.Signer holding a P-256 key, such as an *ecdsa.PrivateKey
+// or a KMS client, and converts its ASN.1 signature to the raw form JWT expects.
+func NewCryptoSigner(s crypto.Signer) (Signer, error) {
+	pub, ok := s.Public().(*ecdsa.PublicKey)
+	if !ok || pub.Curve != elliptic.P256() {
+		return nil, ErrSignerInvalidKey
+	}
+	return &cryptoSigner{signer: s}, nil
+}
+
+func (s *cryptoSigner) Sign(signingInput []byte) ([]byte, error) {
+	digest := sha256.Sum256(signingInput)
+	sig, err := s.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	return rawES256Signature(sig)
+}
+
+// rawES256Signature converts an ASN.1 DER ECDSA signature to r||s, accepting signatures already in that form.
+func rawES256Signature(sig []byte) ([]byte, error) {
+	var der struct {
+		R, S *big.Int
+	}
+	if rest, err := asn1.Unmarshal(sig, &der); err == nil && len(rest) == 0 {
+		if der.R.Sign() <= 0 || der.S.Sign() <= 0 || der.R.BitLen() > 256 || der.S.BitLen() > 256 {
+			return nil, fmt.Errorf("token: invalid ES256 signature")
+		}
+		raw := make([]byte, 64)
+		der.R.FillBytes(raw[:32])
+		der.S.FillBytes(raw[32:])
+		return raw, nil
+	}
+	if len(sig) == 64 {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("token: invalid ES256 signature of %d bytes", len(sig))
+}
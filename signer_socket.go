@@ -0,0 +1,100 @@
+package appstore
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// signRequest and signResponse are exchanged as JSON lines by SocketSigner and ServeSigner.
+// Only the SHA-256 digest of the signing input crosses the socket.
+type signRequest struct {
+	KeyID  string `json:"keyId,omitempty"`
+	Digest []byte `json:"digest"`
+}
+
+type signResponse struct {
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// SocketSigner is a reference Signer that asks a signing service on a local socket for every signature,
+// so the process generating bearer tokens never holds the key. ServeSigner is a stand-in for that service.
+type SocketSigner struct {
+	Network string        // "unix" or "tcp".
+	Address string        // Socket path or host:port of the signing service.
+	KeyID   string        // Passed to the service to pick the key, optional.
+	Timeout time.Duration // Limit for one signature, dial included. Default is five seconds.
+}
+
+func (s *SocketSigner) Sign(signingInput []byte) ([]byte, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	conn, err := net.DialTimeout(s.Network, s.Address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("token: dial signer err %w", err)
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(signingInput)
+	if err = json.NewEncoder(conn).Encode(signRequest{KeyID: s.KeyID, Digest: digest[:]}); err != nil {
+		return nil, fmt.Errorf("token: send to signer err %w", err)
+	}
+	var rsp signResponse
+	if err = json.NewDecoder(bufio.NewReader(conn)).Decode(&rsp); err != nil {
+		return nil, fmt.Errorf("token: read from signer err %w", err)
+	}
+	if rsp.Error != "" {
+		return nil, fmt.Errorf("token: signer err %s", rsp.Error)
+	}
+	return rawES256Signature(rsp.Signature)
+}
+
+// ServeSigner answers SocketSigner requests on l by signing their digests with key, until l is closed.
+// It stands in for a KMS or HSM-backed signing service in development and tests.
+func ServeSigner(l net.Listener, key crypto.Signer) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveSignerConn(conn, key)
+	}
+}
+
+func serveSignerConn(conn net.Conn, key crypto.Signer) {
+	defer conn.Close()
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	enc := json.NewEncoder(conn)
+	for {
+		var req signRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		var rsp signResponse
+		if len(req.Digest) != sha256.Size {
+			rsp.Error = fmt.Sprintf("digest must be %d bytes", sha256.Size)
+		} else if sig, err := key.Sign(rand.Reader, req.Digest, crypto.SHA256); err != nil {
+			rsp.Error = err.Error()
+		} else {
+			rsp.Signature = sig
+		}
+		if err := enc.Encode(rsp); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go ServeSigner(l, key)
+
+	keySigner, err := NewKeySigner(newTestKeyContent(t))
+	if err != nil {
+		t.Fatalf("NewKeySigner() error = %v", err)
+	}
+	cryptoSigner, err := NewCryptoSigner(key)
+	if err != nil {
+		t.Fatalf("NewCryptoSigner() error = %v", err)
+	}
+
+	for name, tt := range map[string]struct {
+		signer Signer
+		verify *ecdsa.PublicKey
+	}{
+		"crypto": {cryptoSigner, &key.PublicKey},
+		"socket": {&SocketSigner{Network: "tcp", Address: l.Addr().String()}, &key.PublicKey},
+		"key":    {keySigner, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := newTestStoreConfig(t, "")
+			if tt.verify != nil {
+				config.KeyContent = nil
+			}
+			config.Signer = tt.signer
+			token := &Token{}
+			token.WithConfig(config)
+			bearer, err := token.GenerateIfExpired()
+			if err != nil {
+				t.Fatalf("GenerateIfExpired() error = %v", err)
+			}
+			if token.AuthKey() != nil {
+				t.Errorf("Token.AuthKey is set although the signer holds the key")
+			}
+			if tt.verify == nil {
+				return
+			}
+			parsed, err := jwt.Parse(bearer, func(*jwt.Token) (interface{}, error) { return tt.verify, nil },
+				jwt.WithValidMethods([]string{"ES256"}))
+			if err != nil || !parsed.Valid {
+				t.Errorf("jwt.Parse() error = %v", err)
+			}
+		})
+	}
+
+	if _, err = (&SocketSigner{Network: "tcp", Address: "127.0.0.1:1"}).Sign([]byte("x")); err == nil {
+		t.Errorf("SocketSigner.Sign() without a service succeeded")
+	}
+}
@@ -1,7 +1,6 @@
 package appstore
 
 import (
-	"bytes"
 	"context"
 	"crypto/x509"
 	"encoding/base64"
@@ -36,178 +35,204 @@ const (
 )
 
 type StoreConfig struct {
-	KeyContent         []byte         // Loads a .p8 certificate
-	KeyID              string         // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
-	BundleID           string         // Your app’s bundle ID
-	Issuer             string         // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
-	Audience           string         // Your audience (aud) for generating the token (some Apple APIs require a specific aud, such as Sign In with Apple ID).
-	Sandbox            bool           // default is Production
-	TokenIssuedAtFunc  func() int64   // The token’s creation time func. Default is current timestamp.
-	TokenExpiredAtFunc func() int64   // The token’s expiration time func. Default is one hour later.
-	TrustedCertPool    *x509.CertPool // The pool of trusted root certificates. Default is a pool containing only Apple Root CA - G3.
+	KeyContent         []byte                     // Loads a .p8 certificate
+	Signer             Signer                     // Signs tokens instead of KeyContent, so the key can stay in an HSM or KMS.
+	KeyProvider        KeyProvider                // Rotates between several keys, switching keys on a 401. Takes precedence over KeyID, KeyContent and Signer.
+	OnKeyUsed          func(keyID string)         // Called with the key ID of every token generated, such as to audit key usage.
+	KeyID              string                     // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
+	BundleID           string                     // Your app’s bundle ID
+	Issuer             string                     // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
+	KeyType            KeyType                    // TeamKey or IndividualKey. Individual keys sign with "sub": "user" and leave Issuer empty. Default is TeamKey.
+	Audience           string                     // Your audience (aud) for generating the token (some Apple APIs require a specific aud, such as Sign In with Apple ID).
+	Sandbox            bool                       // default is Production
+	Environment        Environment                // Production, Sandbox or LocalTesting, which requires BaseURL. Takes precedence over Sandbox when set.
+	BaseURL            string                     // Custom base URL for every request, such as an egress proxy or a local stand-in. Takes precedence over Environment.
+	TokenIssuedAtFunc  func() int64               // The token’s creation time func. Default is current timestamp.
+	TokenExpiredAtFunc func() int64               // The token’s expiration time func. Default is DefaultTokenLifetime after the creation time.
+	TokenRefreshBefore time.Duration              // How long before expiry a new token is signed in the background. Default is DefaultTokenRefreshBefore.
+	TokenClockSkew     time.Duration              // Tolerated difference with Apple's clock. Default is DefaultTokenClockSkew.
+	TrustedCertPool    *x509.CertPool             // The pool of trusted root certificates. Default is a pool containing only Apple Root CA - G3.
+	RetryPolicy        *RetryPolicy               // Retries failed requests, honoring Retry-After on 429 responses. Default is no retry.
+	RateLimiter        *RateLimiter               // Throttles requests per endpoint, see NewRateLimiter. Default is no client-side limit.
+	Instrumentation    Instrumentation            // Observes every request attempt, see NewSlogInstrumentation and NewMetricsInstrumentation.
+	CoalesceReads      bool                       // Shares one in-flight call between concurrent identical GET requests. Default is false.
+	Cache              ResponseCache              // Caches responses of read endpoints, see NewLRUCache. Default is no cache.
+	CacheTTLs          map[Endpoint]time.Duration // How long each endpoint's responses are cached. Default is DefaultCacheTTLs.
 }
 
 type StoreClient struct {
-	Token   *Token
-	httpCli HTTPClient
-	cert    *Cert
-	hostUrl string
+	Token           *Token
+	httpCli         HTTPClient
+	cert            *Cert
+	hostUrl         string
+	environment     Environment
+	retry           *RetryPolicy
+	limiter         *RateLimiter
+	instrumentation Instrumentation
+	coalescer       *coalescer
+	cache           ResponseCache
+	cacheTTLs       map[Endpoint]time.Duration
+	cacheGen        uint32 // bumped by InvalidateCache, so responses fetched before an invalidation are not stored
 }
 
 // NewStoreClient create a appstore server api client
 func NewStoreClient(config *StoreConfig) *StoreClient {
+	return NewStoreClientWithHTTPClient(config, &http.Client{
+		Timeout: 30 * time.Second,
+	})
+}
+
+// NewStoreClientWithHTTPClient creates a appstore server api client with a custom http client.
+func NewStoreClientWithHTTPClient(config *StoreConfig, httpClient HTTPClient) *StoreClient {
 	token := &Token{}
 	token.WithConfig(config)
-	hostUrl := HostProduction
-	if config.Sandbox {
-		hostUrl = HostSandBox
-	}
 
 	client := &StoreClient{
-		Token: token,
-		cert:  newCert(config.TrustedCertPool),
-		httpCli: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		hostUrl: hostUrl,
+		Token:           token,
+		cert:            newCert(config.TrustedCertPool),
+		httpCli:         httpClient,
+		hostUrl:         config.hostURL(),
+		environment:     config.environment(),
+		retry:           config.RetryPolicy,
+		limiter:         config.RateLimiter,
+		instrumentation: config.Instrumentation,
+	}
+	if config.CoalesceReads {
+		client.coalescer = newCoalescer()
+	}
+	if config.Cache != nil {
+		client.cache = config.Cache
+		ttls := config.CacheTTLs
+		if ttls == nil {
+			ttls = DefaultCacheTTLs
+		}
+		// Copied, so changing the map afterwards, DefaultCacheTTLs included, doesn't race with requests.
+		client.cacheTTLs = make(map[Endpoint]time.Duration, len(ttls))
+		for endpoint, ttl := range ttls {
+			client.cacheTTLs[endpoint] = ttl
+		}
 	}
 	return client
 }
 
-// NewStoreClientWithHTTPClient creates a appstore server api client with a custom http client.
-func NewStoreClientWithHTTPClient(config *StoreConfig, httpClient HTTPClient) *StoreClient {
-	token := &Token{}
-	token.WithConfig(config)
-	hostUrl := HostProduction
-	if config.Sandbox {
-		hostUrl = HostSandBox
+// String describes the client without its credentials, so it is safe to log.
+func (c *StoreClient) String() string {
+	if c == nil {
+		return "<nil>"
 	}
+	return fmt.Sprintf("StoreClient{Environment: %s, BaseURL: %s, Token: %s}", c.environment, c.hostUrl, c.Token)
+}
 
-	client := &StoreClient{
-		Token:   token,
-		cert:    newCert(config.TrustedCertPool),
-		httpCli: httpClient,
-		hostUrl: hostUrl,
+// GoString redacts secrets from %#v as well.
+func (c *StoreClient) GoString() string {
+	return "&appstore." + c.String()
+}
+
+// environment resolves the target environment, falling back to the Sandbox flag when Environment is unset.
+func (c *StoreConfig) environment() Environment {
+	if c.Environment != "" {
+		return c.Environment
 	}
-	return client
+	if c.Sandbox {
+		return Sandbox
+	}
+	return Production
 }
 
-func (c *StoreClient) initHttpClient(hc HTTPClient) (DoFunc, error) {
-	authToken, err := c.Token.GenerateIfExpired()
-	if err != nil {
-		return nil, fmt.Errorf("appstore generate token err %w", err)
+// hostURL resolves the base URL that every endpoint path is appended to.
+func (c *StoreConfig) hostURL() string {
+	if c.BaseURL != "" {
+		return strings.TrimSuffix(c.BaseURL, "/")
+	}
+	switch c.environment() {
+	case Sandbox:
+		return HostSandBox
+	case LocalTesting, Xcode:
+		// Neither environment has a public host; requests fail with ErrBaseURLRequired until BaseURL is set.
+		return ""
+	default:
+		return HostProduction
 	}
-	return AddHeader(hc, "Authorization", "Bearer "+authToken), nil
 }
 
-// GetALLSubscriptionStatuses https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses
-func (c *StoreClient) GetALLSubscriptionStatuses(ctx context.Context, originalTransactionId string) (*StatusResponse, error) {
-	URL := c.hostUrl + PathGetALLSubscriptionStatus
-	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
-
-	var client HTTPClient
-	client = c.httpCli
-	client = SetInitializer(client, c.initHttpClient)
-	apiErr := &Error{}
-	client = SetResponseErrorHandler(client, json.Unmarshal, &apiErr)
-	client = RequireResponseStatus(client, http.StatusOK)
-	client = SetRequest(ctx, client, http.MethodGet, URL)
-	rsp := &StatusResponse{}
-	client = SetResponseBodyHandler(client, json.Unmarshal, rsp)
-
-	_, err := client.Do(nil)
-	if apiErr.errorCode != 0 {
-		return nil, apiErr
+// Environment returns the environment the client sends requests to.
+func (c *StoreClient) Environment() Environment {
+	return c.environment
+}
+
+// BaseURL returns the base URL the client prepends to every endpoint path.
+func (c *StoreClient) BaseURL() string {
+	return c.hostUrl
+}
+
+// buildURL expands the path template with escaped path parameters and appends the encoded query.
+func (c *StoreClient) buildURL(path string, pathParams map[string]string, query url.Values) string {
+	for k, v := range pathParams {
+		path = strings.Replace(path, "{"+k+"}", url.PathEscape(v), -1)
 	}
-	if err != nil {
-		return nil, err
+	URL := c.hostUrl + path
+	if len(query) > 0 {
+		URL += "?" + query.Encode()
 	}
-	return rsp, nil
+	return URL
+}
+
+// GetALLSubscriptionStatuses https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses
+func (c *StoreClient) GetALLSubscriptionStatuses(ctx context.Context, originalTransactionId string) (*StatusResponse, error) {
+	rsp, _, err := execute[StatusResponse](ctx, c, &apiRequest{
+		endpoint:   EndpointGetAllSubscriptionStatuses,
+		method:     http.MethodGet,
+		path:       PathGetALLSubscriptionStatus,
+		pathParams: map[string]string{"originalTransactionId": originalTransactionId},
+	})
+	return rsp, err
 }
 
 // GetTransactionInfo https://developer.apple.com/documentation/appstoreserverapi/get_transaction_info
 func (c *StoreClient) GetTransactionInfo(ctx context.Context, transactionId string) (*TransactionInfoResponse, error) {
-	URL := c.hostUrl + PathTransactionInfo
-	URL = strings.Replace(URL, "{transactionId}", transactionId, -1)
-
-	var client HTTPClient
-	client = c.httpCli
-	client = SetInitializer(client, c.initHttpClient)
-	apiErr := &Error{}
-	client = SetResponseErrorHandler(client, json.Unmarshal, &apiErr)
-	client = RequireResponseStatus(client, http.StatusOK)
-	client = SetRequest(ctx, client, http.MethodGet, URL)
-	rsp := &TransactionInfoResponse{}
-	client = SetResponseBodyHandler(client, json.Unmarshal, rsp)
-
-	_, err := client.Do(nil)
-	if apiErr.errorCode != 0 {
-		return nil, apiErr
-	}
-	if err != nil {
-		return nil, err
-	}
-	return rsp, nil
+	rsp, _, err := execute[TransactionInfoResponse](ctx, c, &apiRequest{
+		endpoint:   EndpointGetTransactionInfo,
+		method:     http.MethodGet,
+		path:       PathTransactionInfo,
+		pathParams: map[string]string{"transactionId": transactionId},
+	})
+	return rsp, err
 }
 
 // LookupOrderID https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
 func (c *StoreClient) LookupOrderID(ctx context.Context, orderId string) (*OrderLookupResponse, error) {
-	URL := c.hostUrl + PathLookUp
-	URL = strings.Replace(URL, "{orderId}", orderId, -1)
-
-	var client HTTPClient
-	client = c.httpCli
-	client = SetInitializer(client, c.initHttpClient)
-	apiErr := &Error{}
-	client = SetResponseErrorHandler(client, json.Unmarshal, &apiErr)
-	client = RequireResponseStatus(client, http.StatusOK)
-	client = SetRequest(ctx, client, http.MethodGet, URL)
-	rsp := &OrderLookupResponse{}
-	client = SetResponseBodyHandler(client, json.Unmarshal, rsp)
-
-	_, err := client.Do(nil)
-	if apiErr.errorCode != 0 {
-		return nil, apiErr
-	}
-	if err != nil {
-		return nil, err
-	}
-	return rsp, nil
+	rsp, _, err := execute[OrderLookupResponse](ctx, c, &apiRequest{
+		endpoint:   EndpointLookUpOrderID,
+		method:     http.MethodGet,
+		path:       PathLookUp,
+		pathParams: map[string]string{"orderId": orderId},
+	})
+	return rsp, err
 }
 
 // GetTransactionHistory https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
 func (c *StoreClient) GetTransactionHistory(ctx context.Context, originalTransactionId string, query *url.Values) (responses []*HistoryResponse, err error) {
-	URL := c.hostUrl + PathTransactionHistory
-	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
-
 	if query == nil {
 		query = &url.Values{}
 	}
 
 	for {
-		var client HTTPClient
-		client = c.httpCli
-		client = SetInitializer(client, c.initHttpClient)
-		apiErr := &Error{}
-		client = SetResponseErrorHandler(client, json.Unmarshal, &apiErr)
-		client = RequireResponseStatus(client, http.StatusOK)
-
-		rsp := HistoryResponse{}
-		client = SetResponseBodyHandler(client, json.Unmarshal, &rsp)
-		client = SetRequest(ctx, client, http.MethodGet, URL+"?"+query.Encode())
-		_, errDo := client.Do(nil)
-		if apiErr.errorCode != 0 {
-			return nil, apiErr
-		}
-		if errDo != nil {
-			return nil, errDo
+		rsp, _, err := execute[HistoryResponse](ctx, c, &apiRequest{
+			endpoint:   EndpointGetTransactionHistory,
+			method:     http.MethodGet,
+			path:       PathTransactionHistory,
+			pathParams: map[string]string{"originalTransactionId": originalTransactionId},
+			query:      *query,
+		})
+		if err != nil {
+			return nil, err
 		}
 
-		responses = append(responses, &rsp)
+		responses = append(responses, rsp)
 		if rsp.HasMore && rsp.Revision != "" {
 			query.Set("revision", rsp.Revision)
 		} else {
-			return
+			return responses, nil
 		}
 
 		time.Sleep(10 * time.Millisecond)
@@ -216,39 +241,25 @@ func (c *StoreClient) GetTransactionHistory(ctx context.Context, originalTransac
 
 // GetRefundHistory https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
 func (c *StoreClient) GetRefundHistory(ctx context.Context, originalTransactionId string) (responses []*RefundLookupResponse, err error) {
-	baseURL := c.hostUrl + PathRefundHistory
-	baseURL = strings.Replace(baseURL, "{originalTransactionId}", originalTransactionId, -1)
-	URL := baseURL
+	query := url.Values{}
 
 	for {
-		var client HTTPClient
-		client = c.httpCli
-		client = SetInitializer(client, c.initHttpClient)
-		apiErr := &Error{}
-		client = SetResponseErrorHandler(client, json.Unmarshal, &apiErr)
-		client = RequireResponseStatus(client, http.StatusOK)
-		rsp := RefundLookupResponse{}
-		client = SetRequest(ctx, client, http.MethodGet, URL)
-		client = SetResponseBodyHandler(client, json.Unmarshal, &rsp)
-		_, err = client.Do(nil)
-		if apiErr.errorCode != 0 {
-			return nil, apiErr
-		}
+		rsp, _, err := execute[RefundLookupResponse](ctx, c, &apiRequest{
+			endpoint:   EndpointGetRefundHistory,
+			method:     http.MethodGet,
+			path:       PathRefundHistory,
+			pathParams: map[string]string{"originalTransactionId": originalTransactionId},
+			query:      query,
+		})
 		if err != nil {
 			return nil, err
 		}
 
-		responses = append(responses, &rsp)
-		if !rsp.HasMore {
-			return
-		}
-
-		data := url.Values{}
-		if rsp.Revision != "" {
-			data.Set("revision", rsp.Revision)
-			URL = baseURL + "?" + data.Encode()
+		responses = append(responses, rsp)
+		if rsp.HasMore && rsp.Revision != "" {
+			query.Set("revision", rsp.Revision)
 		} else {
-			return
+			return responses, nil
 		}
 
 		time.Sleep(10 * time.Millisecond)
@@ -257,121 +268,72 @@ func (c *StoreClient) GetRefundHistory(ctx context.Context, originalTransactionI
 
 // SendConsumptionInfo https://developer.apple.com/documentation/appstoreserverapi/send_consumption_information
 func (c *StoreClient) SendConsumptionInfo(ctx context.Context, originalTransactionId string, body ConsumptionRequestBody) (statusCode int, err error) {
-	URL := c.hostUrl + PathConsumptionInfo
-	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
-
-	bodyBuf := new(bytes.Buffer)
-	err = json.NewEncoder(bodyBuf).Encode(body)
-	if err != nil {
-		return 0, err
-	}
-
-	statusCode, _, err = c.Do(ctx, http.MethodPut, URL, bodyBuf)
-	if err != nil {
-		return statusCode, err
-	}
-	return statusCode, nil
+	_, statusCode, err = execute[struct{}](ctx, c, &apiRequest{
+		endpoint:   EndpointSendConsumptionInfo,
+		method:     http.MethodPut,
+		path:       PathConsumptionInfo,
+		pathParams: map[string]string{"originalTransactionId": originalTransactionId},
+		body:       body,
+	})
+	return statusCode, err
 }
 
 // ExtendSubscriptionRenewalDate https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
 func (c *StoreClient) ExtendSubscriptionRenewalDate(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (statusCode int, err error) {
-	URL := c.hostUrl + PathExtendSubscriptionRenewalDate
-	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
-
-	bodyBuf := new(bytes.Buffer)
-	err = json.NewEncoder(bodyBuf).Encode(body)
-	if err != nil {
-		return 0, err
-	}
-
-	statusCode, _, err = c.Do(ctx, http.MethodPut, URL, bodyBuf)
-	if err != nil {
-		return statusCode, err
-	}
-	return statusCode, nil
+	_, statusCode, err = execute[struct{}](ctx, c, &apiRequest{
+		endpoint:   EndpointExtendSubscriptionRenewalDate,
+		method:     http.MethodPut,
+		path:       PathExtendSubscriptionRenewalDate,
+		pathParams: map[string]string{"originalTransactionId": originalTransactionId},
+		body:       body,
+	})
+	return statusCode, err
 }
 
 // ExtendSubscriptionRenewalDateForAll https://developer.apple.com/documentation/appstoreserverapi/extend_subscription_renewal_dates_for_all_active_subscribers
+// Server errors are not retried, since a repeated request could extend renewals twice. Check the outcome
+// with GetSubscriptionRenewalDataStatus before sending it again with the same RequestIdentifier.
 func (c *StoreClient) ExtendSubscriptionRenewalDateForAll(ctx context.Context, body MassExtendRenewalDateRequest) (statusCode int, err error) {
-	URL := HostProduction + PathExtendSubscriptionRenewalDateForAll
-	if c.Token.Sandbox {
-		URL = HostSandBox + PathExtendSubscriptionRenewalDateForAll
-	}
-
-	bodyBuf := new(bytes.Buffer)
-	err = json.NewEncoder(bodyBuf).Encode(body)
-	if err != nil {
-		return 0, err
-	}
-
-	statusCode, _, err = c.Do(ctx, http.MethodPost, URL, bodyBuf)
-	if err != nil {
-		return statusCode, err
-	}
-	return statusCode, nil
+	_, statusCode, err = execute[struct{}](ctx, c, &apiRequest{
+		endpoint: EndpointExtendSubscriptionRenewalDateForAll,
+		method:   http.MethodPost,
+		path:     PathExtendSubscriptionRenewalDateForAll,
+		body:     body,
+	})
+	return statusCode, err
 }
 
 // GetSubscriptionRenewalDataStatus https://developer.apple.com/documentation/appstoreserverapi/get_status_of_subscription_renewal_date_extensions
 func (c *StoreClient) GetSubscriptionRenewalDataStatus(ctx context.Context, productId, requestIdentifier string) (statusCode int, rsp *MassExtendRenewalDateStatusResponse, err error) {
-	URL := HostProduction + PathGetStatusOfSubscriptionRenewalDate
-	if c.Token.Sandbox {
-		URL = HostSandBox + PathGetStatusOfSubscriptionRenewalDate
-	}
-	URL = strings.Replace(URL, "{productId}", productId, -1)
-	URL = strings.Replace(URL, "{requestIdentifier}", requestIdentifier, -1)
-
-	statusCode, body, err := c.Do(ctx, http.MethodGet, URL, nil)
-	if err != nil {
-		return statusCode, nil, err
-	}
-
-	if statusCode != http.StatusOK {
-		return statusCode, nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
-	}
-
-	err = json.Unmarshal(body, &rsp)
-	if err != nil {
-		return statusCode, nil, err
-	}
-
-	return statusCode, rsp, nil
+	rsp, statusCode, err = execute[MassExtendRenewalDateStatusResponse](ctx, c, &apiRequest{
+		endpoint:   EndpointGetSubscriptionRenewalDateStatus,
+		method:     http.MethodGet,
+		path:       PathGetStatusOfSubscriptionRenewalDate,
+		pathParams: map[string]string{"productId": productId, "requestIdentifier": requestIdentifier},
+	})
+	return statusCode, rsp, err
 }
 
 // GetNotificationHistory https://developer.apple.com/documentation/appstoreserverapi/get_notification_history
 func (c *StoreClient) GetNotificationHistory(ctx context.Context, body NotificationHistoryRequest) (responses []NotificationHistoryResponseItem, err error) {
-	baseURL := c.hostUrl + PathGetNotificationHistory
-	URL := baseURL
+	query := url.Values{}
 
 	for {
-		var client HTTPClient
-		client = c.httpCli
-		client = SetInitializer(client, c.initHttpClient)
-		apiErr := &Error{}
-		client = SetResponseErrorHandler(client, json.Unmarshal, &apiErr)
-		client = RequireResponseStatus(client, http.StatusOK)
-		rsp := NotificationHistoryResponses{}
-		rsp.NotificationHistory = make([]NotificationHistoryResponseItem, 0)
-
-		client = SetRequestBodyJSON(client, body)
-		client = SetRequest(ctx, client, http.MethodPost, URL)
-		client = SetResponseBodyHandler(client, json.Unmarshal, &rsp)
-		_, err = client.Do(nil)
-		if apiErr.errorCode != 0 {
-			return nil, apiErr
-		}
+		rsp, _, err := execute[NotificationHistoryResponses](ctx, c, &apiRequest{
+			endpoint:   EndpointGetNotificationHistory,
+			method:     http.MethodPost,
+			path:       PathGetNotificationHistory,
+			query:      query,
+			body:       body,
+			idempotent: true,
+		})
 		if err != nil {
 			return nil, err
 		}
 
 		responses = append(responses, rsp.NotificationHistory...)
-		if !rsp.HasMore {
-			return responses, nil
-		}
-
-		data := url.Values{}
-		if rsp.PaginationToken != "" {
-			data.Set("paginationToken", rsp.PaginationToken)
-			URL = baseURL + "?" + data.Encode()
+		if rsp.HasMore && rsp.PaginationToken != "" {
+			query.Set("paginationToken", rsp.PaginationToken)
 		} else {
 			return responses, nil
 		}
@@ -381,36 +343,43 @@ func (c *StoreClient) GetNotificationHistory(ctx context.Context, body Notificat
 }
 
 // SendRequestTestNotification https://developer.apple.com/documentation/appstoreserverapi/request_a_test_notification
+// On an error response the raw body is returned along with the error, as Error.RawBody also reports it.
 func (c *StoreClient) SendRequestTestNotification(ctx context.Context) (int, []byte, error) {
-	URL := c.hostUrl + PathRequestTestNotification
-
-	return c.Do(ctx, http.MethodPost, URL, nil)
+	rsp, statusCode, err := execute[json.RawMessage](ctx, c, &apiRequest{
+		endpoint: EndpointRequestTestNotification,
+		method:   http.MethodPost,
+		path:     PathRequestTestNotification,
+	})
+	if err != nil {
+		return statusCode, errorBody(err), err
+	}
+	return statusCode, *rsp, nil
 }
 
 // GetTestNotificationStatus https://developer.apple.com/documentation/appstoreserverapi/get_test_notification_status
 func (c *StoreClient) GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (int, []byte, error) {
-	URL := c.hostUrl + PathGetTestNotificationStatus
-	URL = strings.Replace(URL, "{testNotificationToken}", testNotificationToken, -1)
-
-	return c.Do(ctx, http.MethodGet, URL, nil)
+	rsp, statusCode, err := execute[json.RawMessage](ctx, c, &apiRequest{
+		endpoint:   EndpointGetTestNotificationStatus,
+		method:     http.MethodGet,
+		path:       PathGetTestNotificationStatus,
+		pathParams: map[string]string{"testNotificationToken": testNotificationToken},
+	})
+	if err != nil {
+		return statusCode, errorBody(err), err
+	}
+	return statusCode, *rsp, nil
 }
 
 // SetAppAccountToken https://developer.apple.com/documentation/appstoreserverapi/set-app-account-tokenAdd
 func (c *StoreClient) SetAppAccountToken(ctx context.Context, originalTransactionId string, body UpdateAppAccountTokenRequest) (statusCode int, err error) {
-	URL := c.hostUrl + PathSetAppAccountToken
-	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
-
-	bodyBuf := new(bytes.Buffer)
-	err = json.NewEncoder(bodyBuf).Encode(body)
-	if err != nil {
-		return 0, err
-	}
-
-	statusCode, _, err = c.Do(ctx, http.MethodPut, URL, bodyBuf)
-	if err != nil {
-		return statusCode, err
-	}
-	return statusCode, nil
+	_, statusCode, err = execute[struct{}](ctx, c, &apiRequest{
+		endpoint:   EndpointSetAppAccountToken,
+		method:     http.MethodPut,
+		path:       PathSetAppAccountToken,
+		pathParams: map[string]string{"originalTransactionId": originalTransactionId},
+		body:       body,
+	})
+	return statusCode, err
 }
 
 func (c *StoreClient) ParseNotificationV2(tokenStr string) (*jwt.Token, error) {
@@ -470,6 +439,17 @@ func (c *StoreClient) ParseNotificationV2RenewalInfo(signedRenewalInfo string) (
 	return &result, nil
 }
 
+// ParseNotificationV2AppTransactionInfo parses the signedAppTransactionInfo from decoded notification appData
+// (https://developer.apple.com/documentation/appstoreservernotifications/appdata)
+func (c *StoreClient) ParseNotificationV2AppTransactionInfo(signedAppTransactionInfo string) (*JWSAppTransaction, error) {
+	var result JWSAppTransaction
+	if err := c.ParseSignedPayload(signedAppTransactionInfo, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
 // ParseSignedTransactions parse the jws singed transactions
 // Per doc: https://datatracker.ietf.org/doc/html/rfc7515#section-4.1.6
 func (c *StoreClient) ParseSignedTransactions(transactions []string) ([]*JWSTransaction, error) {
@@ -527,39 +507,22 @@ func (c *StoreClient) parseSignedTransaction(transaction string) (*JWSTransactio
 	return tran, nil
 }
 
+// Do sends an authorized request to an arbitrary App Store Server API URL through the same
+// pipeline as the typed endpoints and returns the raw response body.
 // Per doc: https://developer.apple.com/documentation/appstoreserverapi#topics
-func (c *StoreClient) Do(ctx context.Context, method string, url string, body io.Reader) (int, []byte, error) {
-	authToken, err := c.Token.GenerateIfExpired()
-	if err != nil {
-		return 0, nil, fmt.Errorf("appstore generate token err %w", err)
-	}
-
-	req, err := http.NewRequest(method, url, body)
-	if err != nil {
-		return 0, nil, fmt.Errorf("appstore new http request err %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+authToken)
-	req.Header.Set("User-Agent", "App Store Client")
-	req = req.WithContext(ctx)
-
-	resp, err := c.httpCli.Do(req)
-	if err != nil {
-		return 0, nil, fmt.Errorf("appstore http client do err %w", err)
-	}
-	defer resp.Body.Close()
-
-	byteData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return resp.StatusCode, nil, fmt.Errorf("appstore read http body err %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		if rErr, ok := newAppStoreAPIError(byteData, resp.Header); ok {
-			return resp.StatusCode, byteData, rErr
+func (c *StoreClient) Do(ctx context.Context, method string, URL string, body io.Reader) (int, []byte, error) {
+	var reqBody []byte
+	if body != nil {
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("appstore read request body err %w", err)
 		}
+		reqBody = b
 	}
 
-	return resp.StatusCode, byteData, err
+	endpoint := EndpointUnknown
+	if u, err := url.Parse(URL); err == nil {
+		endpoint = matchEndpoint(method, u.Path)
+	}
+	return c.do(ctx, endpoint, method, URL, reqBody, isIdempotentMethod(method), true)
 }
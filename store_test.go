@@ -2,6 +2,14 @@ package appstore
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
@@ -212,3 +220,103 @@ func TestStoreClient_GetNotificationHistory(t *testing.T) {
 		})
 	}
 }
+
+func newTestKeyContent(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func newTestStoreConfig(t *testing.T, baseURL string) *StoreConfig {
+	t.Helper()
+	return &StoreConfig{
+		KeyContent: newTestKeyContent(t),
+		KeyID:      "SKEYID",
+		BundleID:   "fake.bundle.id",
+		Issuer:     "57246542-96fe-1a63-e053-0824d011072a",
+		BaseURL:    baseURL,
+	}
+}
+
+func TestStoreClient_BaseURL(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"requestIdentifier":"req-1","complete":true}`))
+	}))
+	defer srv.Close()
+
+	c := newTestStoreConfig(t, srv.URL+"/")
+	c.Environment = LocalTesting
+	a := NewStoreClient(c)
+	if a.BaseURL() != srv.URL || a.Environment() != LocalTesting {
+		t.Fatalf("BaseURL() = %v, Environment() = %v", a.BaseURL(), a.Environment())
+	}
+
+	if _, err := a.ExtendSubscriptionRenewalDateForAll(context.TODO(), MassExtendRenewalDateRequest{RequestIdentifier: "req-1"}); err != nil {
+		t.Fatalf("ExtendSubscriptionRenewalDateForAll() error = %v", err)
+	}
+	_, rsp, err := a.GetSubscriptionRenewalDataStatus(context.TODO(), "product/1", "req-1")
+	if err != nil || !rsp.Complete {
+		t.Fatalf("GetSubscriptionRenewalDataStatus() rsp = %v, error = %v", rsp, err)
+	}
+
+	want := []string{PathExtendSubscriptionRenewalDateForAll, "/inApps/v1/subscriptions/extend/mass/product/1/req-1"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("requested paths = %v, want %v", paths, want)
+	}
+}
+
+func TestStoreClient_Executor(t *testing.T) {
+	var gotEndpoints []Endpoint
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEndpoints = append(gotEndpoints, EndpointFromRequest(r))
+		if r.Header.Get("Authorization") == "" || r.Header.Get("User-Agent") == "" {
+			t.Errorf("missing auth or user agent header: %v", r.Header)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errorCode":4040009,"errorMessage":"not found"}`))
+	}))
+	defer srv.Close()
+
+	a := NewStoreClient(newTestStoreConfig(t, srv.URL+"/proxy"))
+	statusCode, _, err := a.GetSubscriptionRenewalDataStatus(context.TODO(), "product", "req-1")
+	if statusCode != http.StatusNotFound || !errors.Is(err, StatusRequestNotFoundError) {
+		t.Errorf("GetSubscriptionRenewalDataStatus() statusCode = %v, error = %v", statusCode, err)
+	}
+	if _, err = a.SetAppAccountToken(context.TODO(), "1000", UpdateAppAccountTokenRequest{}); !errors.Is(err, StatusRequestNotFoundError) {
+		t.Errorf("SetAppAccountToken() error = %v", err)
+	}
+
+	want := []Endpoint{EndpointGetSubscriptionRenewalDateStatus, EndpointSetAppAccountToken}
+	if !reflect.DeepEqual(gotEndpoints, want) {
+		t.Errorf("EndpointFromRequest() = %v, want %v", gotEndpoints, want)
+	}
+}
+
+func TestStoreClient_TestNotificationErrorBody(t *testing.T) {
+	const body = `{"errorCode":4040008,"errorMessage":"Test notification not found."}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	a := NewStoreClient(newTestStoreConfig(t, srv.URL))
+	statusCode, rsp, err := a.GetTestNotificationStatus(context.TODO(), "token")
+	if err == nil || statusCode != http.StatusNotFound || string(rsp) != body {
+		t.Errorf("GetTestNotificationStatus() = %d, %q, %v, want the error body with the error", statusCode, rsp, err)
+	}
+	statusCode, rsp, err = a.SendRequestTestNotification(context.TODO())
+	if err == nil || statusCode != http.StatusNotFound || string(rsp) != body {
+		t.Errorf("SendRequestTestNotification() = %d, %q, %v, want the error body with the error", statusCode, rsp, err)
+	}
+}
@@ -1,10 +1,13 @@
 package appstore
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"os"
 	"sync"
 	"time"
@@ -16,81 +19,335 @@ import (
 // Authorize Tokens For App Store Server API Request
 // Doc: https://developer.apple.com/documentation/appstoreserverapi/generating_tokens_for_api_requests
 var (
-	ErrAuthKeyInvalidPem  = errors.New("token: AuthKey must be a valid .p8 PEM file")
-	ErrAuthKeyInvalidType = errors.New("token: AuthKey must be of type ecdsa.PrivateKey")
-	DefaultAudience       = "appstoreconnect-v1"
+	ErrAuthKeyInvalidPem    = errors.New("token: AuthKey must be a valid .p8 PEM file")
+	ErrAuthKeyInvalidType   = errors.New("token: AuthKey must be of type ecdsa.PrivateKey")
+	ErrTokenInvalidLifetime = errors.New("token: invalid iat or exp")
+	ErrKeyTypeMismatch      = errors.New("token: Issuer does not match the key type")
+	DefaultAudience         = "appstoreconnect-v1"
+)
+
+const (
+	MaxTokenLifetime          = 60 * time.Minute // Apple rejects tokens that expire more than 60 minutes after iat.
+	DefaultTokenLifetime      = 30 * time.Minute
+	DefaultTokenRefreshBefore = 5 * time.Minute
+	DefaultTokenClockSkew     = time.Minute
 )
 
 // Token represents an Apple Provider Authentication Token (JSON Web Token).
+// The key material and the bearer are unexported and redacted when the token is printed.
 type Token struct {
-	sync.Mutex
+	mu sync.Mutex
 
-	KeyContent    []byte       // Loads a .p8 certificate
-	KeyID         string       // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
-	BundleID      string       // Your app’s bundle ID
-	Issuer        string       // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
-	Audience      string       // Your audience (aud) for generating the token (some Apple APIs require a specific aud, such as Sign In with Apple ID).
-	Sandbox       bool         // default is Production
-	IssuedAtFunc  func() int64 // The token’s creation time func. Default is current timestamp.
-	ExpiredAtFunc func() int64 // The token’s expiration time func.
+	KeyID         string             // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
+	BundleID      string             // Your app’s bundle ID
+	Issuer        string             // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
+	KeyType       KeyType            // TeamKey or IndividualKey. Default is TeamKey.
+	Audience      string             // Your audience (aud) for generating the token (some Apple APIs require a specific aud, such as Sign In with Apple ID).
+	Sandbox       bool               // default is Production
+	IssuedAtFunc  func() int64       // The token’s creation time func. Default is current timestamp.
+	ExpiredAtFunc func() int64       // The token’s expiration time func.
+	Signer        Signer             // Signs the token instead of KeyContent, see NewCryptoSigner and SocketSigner.
+	KeyProvider   KeyProvider        // Supplies KeyID and Signer for every new token, taking precedence over both.
+	OnKeyUsed     func(keyID string) // Called with the key ID of every token generated, once the Token is unlocked.
+	RefreshBefore time.Duration      // How long before expiry a new token is signed in the background. Default is DefaultTokenRefreshBefore.
+	ClockSkew     time.Duration      // Tolerated difference with Apple's clock; iat is backdated by it unless IssuedAtFunc or ExpiredAtFunc is set. Default is DefaultTokenClockSkew.
 
 	// internal variables
-	AuthKey   *ecdsa.PrivateKey // .p8 private key
-	ExpiredAt int64             // The token’s expiration time, in UNIX time. Tokens that expire more than 60 minutes after the time in iat are not valid (Ex: 1623086400)
-	Bearer    string            // Authorized bearer token
+	ExpiredAt  int64        // The token’s expiration time, in UNIX time. Tokens that expire more than 60 minutes after the time in iat are not valid (Ex: 1623086400)
+	secret     *tokenSecret // behind a pointer, so even printing a Token value through reflection shows an address only
+	refreshing bool         // a background refresh is signing
+	usedKeyID  string       // key ID of the current token, see CurrentKeyID
+}
+
+type tokenSecret struct {
+	keyContent      []byte            // loads a .p8 certificate
+	authKey         *ecdsa.PrivateKey // .p8 private key
+	bearer          string            // authorized bearer token
+	keySignerFor    []byte            // keyContent keySignerCached was parsed from
+	keySignerCached Signer
+}
+
+func (t *Token) secrets() *tokenSecret {
+	if t.secret == nil {
+		t.secret = &tokenSecret{}
+	}
+	return t.secret
+}
+
+// KeyContent returns a copy of the .p8 key content the token is signed with.
+func (t *Token) KeyContent() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]byte(nil), t.secrets().keyContent...)
+}
+
+// SetKeyContent replaces the .p8 key content, which takes effect with the next token.
+func (t *Token) SetKeyContent(keyContent []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.secrets().keyContent = append([]byte(nil), keyContent...)
+}
+
+// AuthKey returns the parsed .p8 private key, nil until a token was signed with KeyContent.
+func (t *Token) AuthKey() *ecdsa.PrivateKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.secrets().authKey
+}
+
+// CurrentKeyID returns the key ID of the current token, or KeyID before the first token.
+// A KeyProvider may switch it at every new token, including one signed in the background.
+func (t *Token) CurrentKeyID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.currentKeyID()
+}
+
+func (t *Token) currentKeyID() string {
+	if t.usedKeyID != "" {
+		return t.usedKeyID
+	}
+	return t.KeyID
+}
+
+// Bearer returns the current bearer token without checking its expiry, see GenerateIfExpired.
+func (t *Token) Bearer() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.secrets().bearer
+}
+
+// String describes the token with its key and bearer redacted, so it is safe to log.
+func (t *Token) String() string {
+	if t == nil {
+		return "<nil>"
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sec := t.secrets()
+	return fmt.Sprintf("Token{KeyID: %s, BundleID: %s, Issuer: %s, KeyType: %s, ExpiredAt: %d, KeyContent: %s, Bearer: %s}",
+		t.currentKeyID(), t.BundleID, t.Issuer, t.KeyType, t.ExpiredAt, redactBytes(sec.keyContent), redactBytes([]byte(sec.bearer)))
+}
+
+// GoString redacts secrets from %#v as well.
+func (t *Token) GoString() string {
+	return "&appstore." + t.String()
 }
 
 func (t *Token) WithConfig(c *StoreConfig) {
-	//t.KeyContent = make([]byte, len(c.KeyContent))
-	//copy(t.KeyContent, c.KeyContent)
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	t.KeyContent = append(t.KeyContent[:0:0], c.KeyContent...)
+	t.secrets().keyContent = append([]byte(nil), c.KeyContent...)
 	t.KeyID = c.KeyID
 	t.BundleID = c.BundleID
 	t.Issuer = c.Issuer
+	t.KeyType = c.KeyType
 	t.Audience = c.Audience
-	t.Sandbox = c.Sandbox
+	t.Sandbox = c.environment() == Sandbox
 	t.IssuedAtFunc = c.TokenIssuedAtFunc
 	t.ExpiredAtFunc = c.TokenExpiredAtFunc
+	t.Signer = c.Signer
+	t.KeyProvider = c.KeyProvider
+	t.OnKeyUsed = c.OnKeyUsed
+	t.RefreshBefore = c.TokenRefreshBefore
+	t.ClockSkew = c.TokenClockSkew
 }
 
-// GenerateIfExpired checks to see if the token is about to expire and generates a new token.
+// GenerateIfExpired returns the current token, generating a new one when it expired or is about to.
+// A token within RefreshBefore of its expiry is still returned while a new one is signed in the background,
+// so callers only wait on signing when the token is missing or inside the ClockSkew margin.
 func (t *Token) GenerateIfExpired() (string, error) {
-	t.Lock()
-	defer t.Unlock()
+	keyUsed := noKeyUsed
+	defer func() { keyUsed() }() // deferred first, so it runs after the unlock
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	if t.Expired() || t.Bearer == "" {
-		err := t.Generate()
-		if err != nil {
+	now := time.Now()
+	if t.secrets().bearer == "" || !now.Add(t.clockSkew()).Before(time.Unix(t.ExpiredAt, 0)) {
+		var err error
+		if keyUsed, err = t.generate(); err != nil {
 			return "", err
 		}
+	} else if !now.Add(t.refreshBefore()).Before(time.Unix(t.ExpiredAt, 0)) {
+		t.refreshInBackground()
 	}
 
-	return t.Bearer, nil
+	return t.secrets().bearer, nil
 }
 
-// Expired checks to see if the token has expired.
+// refresh generates a new token unless another caller already replaced the rejected one.
+func (t *Token) refresh(rejected string) (string, error) {
+	keyUsed := noKeyUsed
+	defer func() { keyUsed() }()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.secrets().bearer == rejected {
+		if t.KeyProvider != nil {
+			t.KeyProvider.KeyRejected(t.currentKeyID())
+		}
+		var err error
+		if keyUsed, err = t.generate(); err != nil {
+			return "", err
+		}
+	}
+	return t.secrets().bearer, nil
+}
+
+// refreshInBackground signs a new token without holding the lock, so callers keep getting the current one.
+// It must be called with t locked.
+func (t *Token) refreshInBackground() {
+	if t.refreshing {
+		return
+	}
+	d, err := t.draft()
+	if err != nil {
+		// GenerateIfExpired reports the error once the current token runs out.
+		return
+	}
+	t.refreshing = true
+	go func() {
+		bearer, err := d.sign()
+		keyUsed := noKeyUsed
+		t.mu.Lock()
+		t.refreshing = false
+		if err == nil && d.expiredAt >= t.ExpiredAt {
+			keyUsed = t.commit(d, bearer)
+		}
+		t.mu.Unlock()
+		keyUsed()
+	}()
+}
+
+// Expired checks to see if the token has expired, or is close enough to expiry that GenerateIfExpired
+// would replace it: within RefreshBefore, or ClockSkew when that is longer.
 func (t *Token) Expired() bool {
-	return time.Now().Unix() >= t.ExpiredAt
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	margin := t.refreshBefore()
+	if skew := t.clockSkew(); skew > margin {
+		margin = skew
+	}
+	return t.secrets().bearer == "" || !time.Now().Add(margin).Before(time.Unix(t.ExpiredAt, 0))
 }
 
 // Generate creates a new token.
 func (t *Token) Generate() error {
-	key, err := t.passKeyFromByte(t.KeyContent)
+	keyUsed := noKeyUsed
+	defer func() { keyUsed() }()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var err error
+	keyUsed, err = t.generate()
+	return err
+}
+
+// generate signs and commits a new token with t locked. The returned OnKeyUsed call is never nil
+// and must be made once t is unlocked.
+func (t *Token) generate() (func(), error) {
+	d, err := t.draft()
+	if err != nil {
+		return noKeyUsed, err
+	}
+	bearer, err := d.sign()
 	if err != nil {
-		return err
+		return noKeyUsed, err
+	}
+	return t.commit(d, bearer), nil
+}
+
+func noKeyUsed() {}
+
+// tokenDraft is a token ready to be signed.
+type tokenDraft struct {
+	signingString string
+	signer        Signer
+	keyID         string
+	expiredAt     int64
+}
+
+// KeyType tells team keys, which App Store Connect issues for the whole team, from individual keys.
+type KeyType string
+
+const (
+	TeamKey       KeyType = "team"       // Signs with the team's Issuer ID. The default.
+	IndividualKey KeyType = "individual" // Signs as the key's user, with "sub": "user" and no Issuer.
+)
+
+// validateKeyType checks that an Issuer is set for team keys only.
+func validateKeyType(keyType KeyType, issuer string) error {
+	switch keyType {
+	case "", TeamKey:
+		if issuer == "" {
+			return fmt.Errorf("%w: team keys require an Issuer", ErrKeyTypeMismatch)
+		}
+	case IndividualKey:
+		if issuer != "" {
+			return fmt.Errorf("%w: individual keys must not set an Issuer", ErrKeyTypeMismatch)
+		}
+	default:
+		return fmt.Errorf("token: unknown key type %q", keyType)
+	}
+	return nil
+}
+
+// claims builds the JWT payload for the key type.
+func (t *Token) claims(issuedAt, expiredAt int64, audience string) jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"iat":   issuedAt,
+		"exp":   expiredAt,
+		"aud":   audience,
+		"nonce": uuid.New(),
+		"bid":   t.BundleID,
+	}
+	if t.KeyType == IndividualKey {
+		claims["sub"] = "user"
+	} else {
+		claims["iss"] = t.Issuer
+	}
+	return claims
+}
+
+// draft resolves the signing key and claims of a new token and checks them against Apple's limits.
+func (t *Token) draft() (*tokenDraft, error) {
+	if err := validateKeyType(t.KeyType, t.Issuer); err != nil {
+		return nil, err
 	}
-	t.AuthKey = key
 
+	keyID := t.KeyID
+	signer := t.Signer
+	if t.KeyProvider != nil {
+		key, err := t.KeyProvider.CurrentKey()
+		if err != nil {
+			return nil, err
+		}
+		keyID, signer = key.KeyID, key.Signer
+	}
+	if signer == nil {
+		var err error
+		if signer, err = t.keySigner(); err != nil {
+			return nil, err
+		}
+	}
+
+	// iat is backdated by the skew only when the lifetime is ours to pick, so an ExpiredAtFunc
+	// returning an hour from now still gives a valid token.
 	now := time.Now()
 	issuedAt := now.Unix()
 	if t.IssuedAtFunc != nil {
 		issuedAt = t.IssuedAtFunc()
+	} else if t.ExpiredAtFunc == nil {
+		issuedAt = now.Add(-t.clockSkew()).Unix()
 	}
-	expiredAt := now.Add(time.Duration(1) * time.Hour).Unix()
+	expiredAt := time.Unix(issuedAt, 0).Add(DefaultTokenLifetime).Unix()
 	if t.ExpiredAtFunc != nil {
 		expiredAt = t.ExpiredAtFunc()
 	}
+	if err := t.validateLifetime(now, issuedAt, expiredAt); err != nil {
+		return nil, err
+	}
+
 	audience := t.Audience
 	if audience == "" {
 		audience = DefaultAudience
@@ -98,31 +355,94 @@ func (t *Token) Generate() error {
 	jwtToken := &jwt.Token{
 		Header: map[string]interface{}{
 			"alg": "ES256",
-			"kid": t.KeyID,
+			"kid": keyID,
 			"typ": "JWT",
 		},
 
-		Claims: jwt.MapClaims{
-			"iss":   t.Issuer,
-			"iat":   issuedAt,
-			"exp":   expiredAt,
-			"aud":   audience,
-			"nonce": uuid.New(),
-			"bid":   t.BundleID,
-		},
+		Claims: t.claims(issuedAt, expiredAt, audience),
 		Method: jwt.SigningMethodES256,
 	}
 
-	bearer, err := jwtToken.SignedString(t.AuthKey)
+	signingString, err := jwtToken.SigningString()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	t.ExpiredAt = expiredAt
-	t.Bearer = bearer
+	return &tokenDraft{signingString: signingString, signer: signer, keyID: keyID, expiredAt: expiredAt}, nil
+}
 
+func (d *tokenDraft) sign() (string, error) {
+	sig, err := d.signer.Sign([]byte(d.signingString))
+	if err != nil {
+		return "", err
+	}
+	if len(sig) != 64 {
+		return "", fmt.Errorf("token: ES256 signature must be 64 bytes, got %d", len(sig))
+	}
+	return d.signingString + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// commit installs a signed token and returns the call of OnKeyUsed, which is made outside the lock
+// so the hook can use the Token.
+func (t *Token) commit(d *tokenDraft, bearer string) func() {
+	t.usedKeyID = d.keyID
+	t.ExpiredAt = d.expiredAt
+	t.secrets().bearer = bearer
+	onKeyUsed := t.OnKeyUsed
+	if onKeyUsed == nil {
+		return noKeyUsed
+	}
+	return func() { onKeyUsed(d.keyID) }
+}
+
+// validateLifetime rejects tokens Apple would refuse: issued in the future, already expired,
+// or valid for more than 60 minutes.
+func (t *Token) validateLifetime(now time.Time, issuedAt, expiredAt int64) error {
+	iat, exp := time.Unix(issuedAt, 0), time.Unix(expiredAt, 0)
+	switch {
+	case iat.After(now.Add(t.clockSkew())):
+		return fmt.Errorf("%w: iat %s is in the future", ErrTokenInvalidLifetime, iat.UTC().Format(time.RFC3339))
+	case !exp.After(now.Add(t.clockSkew())):
+		return fmt.Errorf("%w: exp %s has passed", ErrTokenInvalidLifetime, exp.UTC().Format(time.RFC3339))
+	case exp.Sub(iat) > MaxTokenLifetime:
+		return fmt.Errorf("%w: lifetime %s exceeds %s", ErrTokenInvalidLifetime, exp.Sub(iat), MaxTokenLifetime)
+	}
 	return nil
 }
 
+// keySigner parses KeyContent once and signs with it until KeyContent changes.
+func (t *Token) keySigner() (Signer, error) {
+	sec := t.secrets()
+	if sec.keySignerFor != nil && bytes.Equal(sec.keySignerFor, sec.keyContent) {
+		return sec.keySignerCached, nil
+	}
+	key, err := t.passKeyFromByte(sec.keyContent)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := NewCryptoSigner(key)
+	if err != nil {
+		return nil, err
+	}
+	sec.authKey = key
+	sec.keySignerFor = append([]byte(nil), sec.keyContent...)
+	sec.keySignerCached = signer
+	return signer, nil
+}
+
+func (t *Token) refreshBefore() time.Duration {
+	if t.RefreshBefore > 0 {
+		return t.RefreshBefore
+	}
+	return DefaultTokenRefreshBefore
+}
+
+func (t *Token) clockSkew() time.Duration {
+	if t.ClockSkew > 0 {
+		return t.ClockSkew
+	}
+	return DefaultTokenClockSkew
+}
+
 // loadKeyFromFile loads a .p8 certificate from a local file and returns a *ecdsa.PrivateKey.
 func (t *Token) loadKeyFromFile(filename string) (*ecdsa.PrivateKey, error) {
 	bytes, err := os.ReadFile(filename)
@@ -0,0 +1,196 @@
+package appstore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestToken_Lifetime(t *testing.T) {
+	now := time.Now().Unix()
+	for name, tt := range map[string]struct {
+		iat, exp func() int64
+		wantErr  bool
+	}{
+		"default":         {},
+		"one hour":        {iat: func() int64 { return now }, exp: func() int64 { return now + 3600 }},
+		"exp in one hour": {exp: func() int64 { return now + 3600 }},
+		"too long":        {iat: func() int64 { return now }, exp: func() int64 { return now + 3601 }, wantErr: true},
+		"future iat":      {iat: func() int64 { return now + 600 }, wantErr: true},
+		"expired":         {iat: func() int64 { return now - 1200 }, exp: func() int64 { return now - 600 }, wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := newTestStoreConfig(t, "")
+			config.TokenIssuedAtFunc, config.TokenExpiredAtFunc = tt.iat, tt.exp
+			token := &Token{}
+			token.WithConfig(config)
+			_, err := token.GenerateIfExpired()
+			if tt.wantErr != (err != nil) || (err != nil && !errors.Is(err, ErrTokenInvalidLifetime)) {
+				t.Errorf("GenerateIfExpired() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestToken_Refresh(t *testing.T) {
+	token := &Token{}
+	token.WithConfig(newTestStoreConfig(t, ""))
+	first, err := token.GenerateIfExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authKey := token.AuthKey()
+	if err = token.Generate(); err != nil || token.AuthKey() != authKey {
+		t.Fatalf("Generate() error = %v, parsed the key again", err)
+	}
+	if exp := time.Until(time.Unix(token.ExpiredAt, 0)); exp > DefaultTokenLifetime || exp < DefaultTokenLifetime-2*time.Minute {
+		t.Errorf("token expires in %s, want about %s", exp, DefaultTokenLifetime)
+	}
+
+	// Inside the refresh margin the current token keeps being served while a new one is signed.
+	keySigner := token.secret.keySignerCached
+	release := make(chan struct{})
+	token.mu.Lock()
+	current := token.secret.bearer
+	token.RefreshBefore = time.Hour
+	token.Signer = SignerFunc(func(signingInput []byte) ([]byte, error) {
+		<-release
+		return keySigner.Sign(signingInput)
+	})
+	token.mu.Unlock()
+
+	if got, err := token.GenerateIfExpired(); err != nil || got != current || got == first {
+		t.Fatalf("GenerateIfExpired() = %v, %v, want the current token without waiting", got == current, err)
+	}
+	close(release)
+	for i := 0; ; i++ {
+		token.mu.Lock()
+		refreshed := token.secret.bearer != current && !token.refreshing
+		token.mu.Unlock()
+		if refreshed {
+			break
+		}
+		if i == 1000 {
+			t.Fatalf("background refresh never replaced the token")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestToken_Expired(t *testing.T) {
+	token := &Token{}
+	token.WithConfig(newTestStoreConfig(t, ""))
+	if !token.Expired() {
+		t.Errorf("Expired() = false before the first token")
+	}
+	if err := token.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if token.Expired() {
+		t.Errorf("Expired() = true for a fresh token")
+	}
+
+	// A token still valid for less than the refresh margin would expire on the wire.
+	token.mu.Lock()
+	token.ExpiredAt = time.Now().Add(DefaultTokenRefreshBefore - time.Minute).Unix()
+	token.mu.Unlock()
+	if !token.Expired() {
+		t.Errorf("Expired() = false inside the refresh margin")
+	}
+}
+
+func TestToken_OnKeyUsed(t *testing.T) {
+	token := &Token{}
+	config := newTestStoreConfig(t, "")
+	config.TokenRefreshBefore = time.Hour
+	used := make(chan string, 4)
+	config.OnKeyUsed = func(keyID string) {
+		// The hook may use the Token, which it couldn't while the token was locked.
+		used <- keyID + " " + token.CurrentKeyID() + " " + strings.Fields(token.String())[0]
+	}
+	token.WithConfig(config)
+
+	done := make(chan error)
+	go func() {
+		_, err := token.GenerateIfExpired() // signs the first token
+		if err == nil {
+			_, err = token.GenerateIfExpired() // refreshes in the background, inside RefreshBefore
+		}
+		done <- err
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case got := <-used:
+			if got != "SKEYID SKEYID Token{KeyID:" {
+				t.Errorf("OnKeyUsed saw %q", got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnKeyUsed call %d never completed", i+1)
+		}
+		if i == 0 {
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = token.CurrentKeyID() // races with the background commit unless locked
+	}
+}
+
+func TestToken_KeyType(t *testing.T) {
+	config := newTestStoreConfig(t, "")
+	config.KeyType = IndividualKey
+	if err := config.Validate(); !errors.Is(err, ErrKeyTypeMismatch) {
+		t.Errorf("Validate() error = %v, want ErrKeyTypeMismatch for an individual key with an Issuer", err)
+	}
+	if _, err := NewValidatedStoreClient(config); !errors.Is(err, ErrKeyTypeMismatch) {
+		t.Errorf("NewValidatedStoreClient() error = %v, want ErrKeyTypeMismatch at construction", err)
+	}
+
+	config.Issuer = ""
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	token := &Token{}
+	token.WithConfig(config)
+	bearer, err := token.GenerateIfExpired()
+	if err != nil {
+		t.Fatalf("GenerateIfExpired() error = %v", err)
+	}
+	var claims jwt.MapClaims
+	if _, _, err = jwt.NewParser().ParseUnverified(bearer, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, hasIss := claims["iss"]; claims["sub"] != "user" || hasIss || claims["bid"] != config.BundleID {
+		t.Errorf("claims = %v, want sub user and no iss", claims)
+	}
+
+	config.KeyType = TeamKey
+	token.WithConfig(config)
+	if err = token.Generate(); !errors.Is(err, ErrKeyTypeMismatch) {
+		t.Errorf("Generate() error = %v, want ErrKeyTypeMismatch for a team key without an Issuer", err)
+	}
+}
+
+func TestToken_Redaction(t *testing.T) {
+	config := newTestStoreConfig(t, "")
+	a := NewStoreClient(config)
+	bearer, err := a.Token.GenerateIfExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		fmt.Sprintf("%v", a), fmt.Sprintf("%+v", a), fmt.Sprintf("%#v", a),
+		fmt.Sprintf("%s", a.Token), fmt.Sprintf("%+v", config), fmt.Sprintf("%+v", *config),
+	} {
+		if strings.Contains(s, bearer) || strings.Contains(s, "PRIVATE KEY") || strings.Contains(s, string(config.KeyContent[40:60])) {
+			t.Errorf("formatted value %q leaks a secret", s)
+		}
+	}
+	if a.Token.Bearer() != bearer || string(a.Token.KeyContent()) != string(config.KeyContent) {
+		t.Errorf("Token accessors don't return the key material")
+	}
+}